@@ -0,0 +1,160 @@
+package goproc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// ProcessParam.Labelsを子プロセスに渡す環境変数の接頭辞
+const LabelEnvPrefix = "GOPROC_LABEL_"
+
+// Aggregateでまとめる単位
+type GroupBy string
+
+const (
+	GroupByName   GroupBy = "name"
+	GroupByExe    GroupBy = "exe"
+	GroupByUser   GroupBy = "user"
+	GroupByCgroup GroupBy = "cgroup"
+)
+
+// GroupByLabel ProcessParam.Labelsで付けたラベルの値でまとめる
+func GroupByLabel(key string) GroupBy {
+	return GroupBy("label:" + key)
+}
+
+// まとめたプロセスの合計。メモリとI/Oはバイト数
+type ProcessGroup struct {
+	Key        string     `json:"key"`
+	Count      int        `json:"count"`
+	Pids       []int      `json:"pids"`
+	CpuPercent float64    `json:"cpuPercent"`
+	Rss        uint64     `json:"rss"`
+	Pss        uint64     `json:"pss"`
+	NumThreads int        `json:"numThreads"`
+	NumFDs     int        `json:"numFds"`
+	IO         IOCounters `json:"io"`
+	IORates    IORates    `json:"ioRates"`
+}
+
+type ProcessGroups []ProcessGroup
+
+// Aggregate プロセスをキーごとにまとめて件数と使用量の合計を返す。CPU使用率の多い順
+// キーが取れないプロセスは空文字のグループになる
+func Aggregate(ps Processes, by GroupBy) (ProcessGroups, error) {
+	key, err := groupKey(by)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string]*ProcessGroup{}
+	for i := range ps {
+		p := &ps[i]
+		k := key(p)
+		g, ok := groups[k]
+		if !ok {
+			g = &ProcessGroup{Key: k, Pids: []int{}}
+			groups[k] = g
+		}
+		g.Count++
+		g.Pids = append(g.Pids, p.Pid)
+		g.CpuPercent += p.CpuPercent
+		g.Rss += rssBytes(p)
+		if p.Memory != nil {
+			g.Pss += p.Memory.Pss
+		}
+		g.NumThreads += p.NumThreads
+		g.NumFDs += p.NumFDs
+		if p.IO != nil {
+			g.IO.add(p.IO)
+		}
+		if p.IORates != nil {
+			g.IORates.ReadBytes += p.IORates.ReadBytes
+			g.IORates.WriteBytes += p.IORates.WriteBytes
+			g.IORates.ReadCount += p.IORates.ReadCount
+			g.IORates.WriteCount += p.IORates.WriteCount
+			g.IORates.CancelledWriteBytes += p.IORates.CancelledWriteBytes
+		}
+	}
+
+	ret := ProcessGroups{}
+	for _, g := range groups {
+		// 各プロセスの値はCPU使用率の表し方を合わせてあるので丸めるだけ
+		g.CpuPercent = math.Round(g.CpuPercent*10) / 10
+		ret = append(ret, *g)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].CpuPercent != ret[j].CpuPercent {
+			return ret[i].CpuPercent > ret[j].CpuPercent
+		}
+		return ret[i].Key < ret[j].Key
+	})
+	return ret, nil
+}
+
+// groupKey まとめるキーを取り出す関数を返す
+func groupKey(by GroupBy) (func(p *Process) string, error) {
+	switch by {
+	case GroupByName:
+		return func(p *Process) string { return p.Name }, nil
+	case GroupByExe:
+		return func(p *Process) string { return p.Exe }, nil
+	case GroupByUser:
+		return processUser, nil
+	case GroupByCgroup:
+		return func(p *Process) string { return cgroupString(p, func(c *CgroupInfo) string { return c.Path }) }, nil
+	}
+	if strings.HasPrefix(string(by), "label:") {
+		label := strings.TrimPrefix(string(by), "label:")
+		// 一覧(ListProcesses)は環境変数を読まないので、ここで取得する(渡されたプロセスには書き込まない)
+		labels := map[int]map[string]string{}
+		return func(p *Process) string {
+			if p.Labels != nil {
+				return p.Labels[label]
+			}
+			l, ok := labels[p.Pid]
+			if !ok {
+				l = getLabels(p.Pid)
+				labels[p.Pid] = l
+			}
+			return l[label]
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown group by: %q", by)
+}
+
+// labelsFromEnv 環境変数からラベルを取り出す
+func labelsFromEnv(env map[string]string) map[string]string {
+	ret := map[string]string{}
+	for k, v := range env {
+		if strings.HasPrefix(k, LabelEnvPrefix) {
+			ret[strings.TrimPrefix(k, LabelEnvPrefix)] = v
+		}
+	}
+	return ret
+}
+
+// getLabels 指定されたPIDのラベルを返す。環境変数が読めなければ空
+func getLabels(pid int) map[string]string {
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return map[string]string{}
+	}
+	envs, err := GetEnviron(p)
+	if err != nil {
+		return map[string]string{}
+	}
+	return labelsFromEnv(EnvToMap(envs))
+}
+
+// rssBytes RSSのバイト数。smapsが読めればそちらの値の方が正確
+func rssBytes(p *Process) uint64 {
+	if p.Memory != nil && p.Memory.Rss > 0 {
+		return p.Memory.Rss
+	}
+	return uint64(parseByteString(p.Rss))
+}
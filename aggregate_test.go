@@ -0,0 +1,54 @@
+package goproc
+
+import (
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	ps := Processes{
+		{Name: "worker", Pid: 10, CpuPercent: 10.25, Rss: "1.00MB", NumThreads: 2, NumFDs: 5,
+			IO: &IOCounters{ReadBytes: 100}, IORates: &IORates{ReadBytes: 10}, Labels: map[string]string{"app": "batch"}},
+		{Name: "worker", Pid: 11, CpuPercent: 20.25, Rss: "2.00MB", NumThreads: 3, NumFDs: 6,
+			IO: &IOCounters{ReadBytes: 50}, Memory: &MemoryDetail{Rss: 3 << 20, Pss: 1 << 20}, Labels: map[string]string{"app": "batch"}},
+		{Name: "java", Pid: 20, CpuPercent: 5, Rss: "512.00MB", NumThreads: 100},
+	}
+
+	groups, err := Aggregate(ps, GroupByName)
+	if err != nil {
+		t.Fatalf("Aggregate = %s, Failed", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("Aggregate = %d, expect = 2, Failed", len(groups))
+	}
+	g := groups[0]
+	if g.Key != "worker" || g.Count != 2 || len(g.Pids) != 2 {
+		t.Errorf("group = %#v, Failed", g)
+	}
+	if g.CpuPercent != 30.5 || g.Rss != 4<<20 || g.Pss != 1<<20 || g.NumThreads != 5 || g.NumFDs != 11 {
+		t.Errorf("group total = %#v, Failed", g)
+	}
+	if g.IO.ReadBytes != 150 || g.IORates.ReadBytes != 10 {
+		t.Errorf("group io = %#v, %#v, Failed", g.IO, g.IORates)
+	}
+
+	groups, err = Aggregate(ps, GroupByLabel("app"))
+	if err != nil {
+		t.Fatalf("Aggregate(label) = %s, Failed", err)
+	}
+	if len(groups) != 2 || groups[0].Key != "batch" || groups[1].Key != "" {
+		t.Errorf("Aggregate(label) = %#v, Failed", groups)
+	}
+	// ラベルを取得しても渡した一覧には書き込まない
+	if ps[2].Labels != nil {
+		t.Errorf("Labels = %v, expect = nil, Failed", ps[2].Labels)
+	}
+
+	if _, err := Aggregate(ps, GroupBy("pid")); err == nil {
+		t.Error("Aggregate(unknown) = nil, Failed")
+	}
+
+	labels := labelsFromEnv(map[string]string{LabelEnvPrefix + "app": "jetty", "PATH": "/bin"})
+	if len(labels) != 1 || labels["app"] != "jetty" {
+		t.Errorf("labelsFromEnv = %v, Failed", labels)
+	}
+}
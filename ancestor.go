@@ -0,0 +1,90 @@
+package goproc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// 親をたどった先のプロセス
+type Ancestor struct {
+	Pid       int       `json:"pid"`
+	Ppid      int       `json:"ppid"`
+	Name      string    `json:"name"`
+	Exe       string    `json:"exe"`
+	Cmdline   string    `json:"cmdline"`
+	User      string    `json:"user"`
+	StartTime time.Time `json:"startTime"`
+}
+
+// GetAncestors 指定されたPIDの親から順にinit(PID 1)までたどって返す。指定されたプロセス自身は含まない
+// 権限が無くて取れない項目は空になる
+func GetAncestors(pid int) ([]Ancestor, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("Don't get process, when pid is %d", pid)
+	}
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return nil, err
+	}
+	ppid, err := p.Ppid()
+	if err != nil {
+		return nil, err
+	}
+	var start time.Time
+	if createtime, err := p.CreateTime(); err == nil && createtime > 0 {
+		start = time.Unix(createtime/1000, (createtime%1000)*int64(time.Millisecond))
+	}
+	return walkAncestors(pid, int(ppid), start, getAncestor), nil
+}
+
+// walkAncestors ppidから親をたどる。startは子の起動時刻
+// 子より後に起動した親は、親が終了した後にPIDが再利用された別のプロセスなのでそこで止める
+// (Winは親が終了しても親のPIDが残り、再利用されることがある)
+func walkAncestors(pid int, ppid int, start time.Time, get func(pid int) (*Ancestor, error)) []Ancestor {
+	ret := []Ancestor{}
+	// 循環しないはずだが、念のため一度たどったPIDで止める
+	visited := map[int]bool{pid: true}
+	for cur := ppid; cur > 0 && !visited[cur]; {
+		visited[cur] = true
+		a, err := get(cur)
+		if err != nil {
+			// たどっている間に親が終了した
+			break
+		}
+		if !start.IsZero() && a.StartTime.After(start) {
+			break
+		}
+		ret = append(ret, *a)
+		cur, start = a.Ppid, a.StartTime
+	}
+	return ret
+}
+
+// getAncestor 1つ分の情報を返す
+func getAncestor(pid int) (*Ancestor, error) {
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return nil, err
+	}
+	ret := &Ancestor{Pid: pid}
+	ret.Name, err = p.Name()
+	if err != nil {
+		return nil, err
+	}
+	ppid, _ := p.Ppid()
+	ret.Ppid = int(ppid)
+	ret.Exe, _ = p.Exe()
+	ret.Cmdline, _ = p.Cmdline()
+	if o, err := getOwner(p); err == nil {
+		ret.User = o.EffectiveUser
+		if ret.User == "" {
+			ret.User = o.User
+		}
+	}
+	if createtime, err := p.CreateTime(); err == nil && createtime > 0 {
+		ret.StartTime = time.Unix(createtime/1000, (createtime%1000)*int64(time.Millisecond))
+	}
+	return ret, nil
+}
@@ -0,0 +1,38 @@
+package goproc_test
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/gozuk16/goproc"
+)
+
+func TestGetAncestors(t *testing.T) {
+	as, err := goproc.GetAncestors(os.Getpid())
+	if err != nil {
+		t.Fatalf("GetAncestors = %s, Failed", err)
+	}
+	if len(as) == 0 || as[0].Pid != os.Getppid() {
+		t.Fatalf("GetAncestors = %#v, Failed", as)
+	}
+	for i, a := range as {
+		if a.Name == "" {
+			t.Errorf("GetAncestors[%d] = %#v, Failed", i, a)
+		}
+		if i > 0 && as[i-1].Ppid != a.Pid {
+			t.Errorf("GetAncestors[%d] = %d, expect = %d, Failed", i, a.Pid, as[i-1].Ppid)
+		}
+		if i > 0 && a.StartTime.After(as[i-1].StartTime) {
+			t.Errorf("GetAncestors[%d] = %s, after child %s, Failed", i, a.StartTime, as[i-1].StartTime)
+		}
+	}
+	// Winにはinitが無い
+	if last := as[len(as)-1]; runtime.GOOS != "windows" && last.Pid != 1 {
+		t.Errorf("GetAncestors last = %#v, expect init, Failed", last)
+	}
+
+	if _, err := goproc.GetAncestors(0); err == nil {
+		t.Error("GetAncestors(0) = nil, Failed")
+	}
+}
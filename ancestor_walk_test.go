@@ -0,0 +1,34 @@
+package goproc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWalkAncestors(t *testing.T) {
+	base := time.Now()
+	procs := map[int]*Ancestor{
+		30: {Pid: 30, Ppid: 20, StartTime: base.Add(-1 * time.Hour)},
+		20: {Pid: 20, Ppid: 10, StartTime: base.Add(-2 * time.Hour)},
+		// 20の親のPIDが再利用されて、20より後に起動した別のプロセスになっている
+		10: {Pid: 10, Ppid: 1, StartTime: base.Add(-1 * time.Minute)},
+		1:  {Pid: 1, StartTime: base.Add(-3 * time.Hour)},
+	}
+	get := func(pid int) (*Ancestor, error) {
+		if a, ok := procs[pid]; ok {
+			return a, nil
+		}
+		return nil, errors.New("no such process")
+	}
+
+	got := walkAncestors(40, 30, base, get)
+	if len(got) != 2 || got[0].Pid != 30 || got[1].Pid != 20 {
+		t.Errorf("walkAncestors = %#v, Failed", got)
+	}
+
+	procs[10].StartTime = base.Add(-150 * time.Minute)
+	if got := walkAncestors(40, 30, base, get); len(got) != 4 || got[3].Pid != 1 {
+		t.Errorf("walkAncestors = %#v, Failed", got)
+	}
+}
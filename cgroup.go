@@ -0,0 +1,184 @@
+package goproc
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-shellwords"
+)
+
+// cgroup v2でサービスを動かす時の設定(Linuxのみ)
+type CgroupParam struct {
+	// 作成するcgroupの名前。空ならコマンド名にする
+	Name string `json:"name"`
+	// 親のcgroup(cgroupのマウントポイントからの相対パス)。空ならgoproc
+	Parent string `json:"parent"`
+	// memory.maxに書く値("512M", "max"等)。空なら設定しない
+	MemoryMax string `json:"memoryMax"`
+	// cpu.maxに書く値("50000 100000"なら1コアの50%)。空なら設定しない
+	CpuMax string `json:"cpuMax"`
+	// pids.maxに書く値("256", "max"等)。空なら設定しない
+	PidsMax string `json:"pidsMax"`
+	// io.weightに書く値(1-10000)。0なら設定しない
+	IOWeight int `json:"ioWeight"`
+}
+
+const defaultCgroupParent = "goproc"
+
+// CgroupPath サービスのcgroupのパス(/proc/<pid>/cgroupと同じくマウントポイントからの絶対パス)を返す
+func CgroupPath(param ProcessParam) string {
+	if param.Cgroup == nil {
+		return ""
+	}
+	parent := param.Cgroup.Parent
+	if parent == "" {
+		parent = defaultCgroupParent
+	}
+	name := param.Cgroup.Name
+	if name == "" {
+		command := param.Command
+		if command == "" {
+			// StartServiceと同じくArgsの1つ目をコマンドと見なす
+			if args, err := shellwords.Parse(param.Args); err == nil && len(args) > 0 {
+				command = args[0]
+			}
+		}
+		name = filepath.Base(command)
+	}
+	return path.Join("/", parent, name)
+}
+
+// StopServiceByCgroup サービスのcgroupに属する全プロセスを終了させる(デーモン化して親から外れたプロセスも含む)
+func StopServiceByCgroup(param ProcessParam) error {
+	if param.Cgroup == nil {
+		return nil
+	}
+	return KillCgroup(CgroupPath(param))
+}
+
+// プロセスが属するcgroupとコンテナ・systemdの情報
+type CgroupInfo struct {
+	// cgroup v2のパス(v1のみの環境ではmemoryかcpuのパス)
+	Path string `json:"path"`
+	// v1のコントローラー毎のパス
+	V1Paths          map[string]string `json:"v1Paths,omitempty"`
+	ContainerID      string            `json:"containerId"`
+	ContainerRuntime string            `json:"containerRuntime"`
+	SystemdUnit      string            `json:"systemdUnit"`
+	SystemdSlice     string            `json:"systemdSlice"`
+	// cgroup全体の使用量。読めなければ0
+	MemoryCurrent uint64 `json:"memoryCurrent"`
+	CpuUsageUsec  uint64 `json:"cpuUsageUsec"`
+}
+
+// コンテナIDのパターン(docker-<id>.scope、cri-containerd-<id>.scope、/docker/<id>、/kubepods/.../<id>等)
+var containerIDPattern = regexp.MustCompile(`(?:^|[/-])([0-9a-f]{64})(?:\.scope)?$`)
+
+// コンテナIDの前に付くプレフィックスとランタイムの対応
+var containerRuntimePrefixes = []struct {
+	prefix  string
+	runtime string
+}{
+	{"cri-containerd-", "containerd"},
+	{"crio-", "cri-o"},
+	{"libpod-", "podman"},
+	{"docker-", "docker"},
+}
+
+// systemdのユニットの拡張子
+var systemdUnitSuffixes = []string{".service", ".scope", ".socket", ".mount", ".swap", ".timer"}
+
+// parseProcCgroup /proc/<pid>/cgroupをパースする
+func parseProcCgroup(content string) *CgroupInfo {
+	ret := &CgroupInfo{V1Paths: map[string]string{}}
+	for _, line := range strings.Split(content, "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(strings.TrimSpace(line), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[0] == "0" && fields[1] == "" {
+			ret.Path = fields[2]
+			continue
+		}
+		for _, ctrl := range strings.Split(fields[1], ",") {
+			ret.V1Paths[ctrl] = fields[2]
+		}
+	}
+
+	// hybridだとv2は空("/")のことがあるので、v1のパスも候補にする
+	candidates := []string{ret.Path, ret.V1Paths["name=systemd"], ret.V1Paths["memory"], ret.V1Paths["cpu"], ret.V1Paths["pids"]}
+	if p := firstNonRoot(ret.V1Paths["memory"], ret.V1Paths["cpu"]); p != "" && (ret.Path == "" || ret.Path == "/") {
+		ret.Path = p
+	}
+	if len(ret.V1Paths) == 0 {
+		ret.V1Paths = nil
+	}
+
+	for _, c := range candidates {
+		if ret.ContainerID == "" {
+			ret.ContainerID, ret.ContainerRuntime = containerFromCgroup(c)
+		}
+		if ret.SystemdUnit == "" && ret.SystemdSlice == "" {
+			ret.SystemdUnit, ret.SystemdSlice = systemdFromCgroup(c)
+		}
+	}
+	return ret
+}
+
+// firstNonRoot "/"や空でない最初のパスを返す
+func firstNonRoot(paths ...string) string {
+	for _, p := range paths {
+		if p != "" && p != "/" {
+			return p
+		}
+	}
+	return ""
+}
+
+// containerFromCgroup cgroupのパスからコンテナIDとランタイムを推測する
+func containerFromCgroup(cgpath string) (string, string) {
+	elems := strings.Split(cgpath, "/")
+	// 内側(末尾)のコンテナを優先する
+	for i := len(elems) - 1; i >= 0; i-- {
+		m := containerIDPattern.FindStringSubmatch(elems[i])
+		if m == nil {
+			continue
+		}
+		for _, p := range containerRuntimePrefixes {
+			if strings.HasPrefix(elems[i], p.prefix) {
+				return m[1], p.runtime
+			}
+		}
+		switch {
+		case strings.Contains(cgpath, "/docker/"):
+			return m[1], "docker"
+		case strings.Contains(cgpath, "/libpod_parent/"):
+			return m[1], "podman"
+		case strings.Contains(cgpath, "kubepods"):
+			return m[1], "kubernetes"
+		}
+		return m[1], ""
+	}
+	return "", ""
+}
+
+// systemdFromCgroup cgroupのパスからsystemdのユニットとスライスを取り出す
+func systemdFromCgroup(cgpath string) (string, string) {
+	var unit, slice string
+	for _, e := range strings.Split(cgpath, "/") {
+		if strings.HasSuffix(e, ".slice") {
+			slice = e
+			continue
+		}
+		for _, s := range systemdUnitSuffixes {
+			if strings.HasSuffix(e, s) {
+				unit = e
+				break
+			}
+		}
+	}
+	return unit, slice
+}
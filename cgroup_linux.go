@@ -0,0 +1,390 @@
+package goproc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// CgroupRoot cgroup v2のマウントポイント。空なら/sys/fs/cgroup、/sys/fs/cgroup/unified(hybrid)の順に探す
+var CgroupRoot = ""
+
+// サービス用に作成したcgroup
+type cgroup struct {
+	// /proc/<pid>/cgroupと同じ表記のパス
+	name string
+	// cgroupfs上の絶対パス
+	dir string
+	fd  *os.File
+}
+
+// cgroupRoot cgroup v2のマウントポイントを返す
+func cgroupRoot() (string, error) {
+	if CgroupRoot != "" {
+		return CgroupRoot, nil
+	}
+	for _, dir := range []string{"/sys/fs/cgroup", "/sys/fs/cgroup/unified"} {
+		if isExistFile(filepath.Join(dir, "cgroup.controllers")) {
+			return dir, nil
+		}
+	}
+	return "", errors.New("cgroup v2 is not mounted")
+}
+
+// createCgroup サービス用のcgroupを作成してリソース制御の値を書き込む
+func createCgroup(param ProcessParam) (*cgroup, error) {
+	if param.Cgroup == nil {
+		return nil, nil
+	}
+	root, err := cgroupRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cgroup{name: CgroupPath(param)}
+	c.dir = filepath.Join(root, c.name)
+
+	// 残っていたcgroupを使う時は、指定の無い値も既定値(制限なし)に戻す
+	settings := []struct {
+		controller string
+		file       string
+		value      string
+		reset      string
+	}{
+		{"memory", "memory.max", param.Cgroup.MemoryMax, "max"},
+		{"cpu", "cpu.max", param.Cgroup.CpuMax, "max"},
+		{"pids", "pids.max", param.Cgroup.PidsMax, "max"},
+		{"io", "io.weight", "", "default 100"},
+	}
+	if param.Cgroup.IOWeight > 0 {
+		settings[3].value = strconv.Itoa(param.Cgroup.IOWeight)
+	}
+
+	controllers := []string{}
+	for _, s := range settings {
+		if s.value != "" {
+			controllers = append(controllers, s.controller)
+		}
+	}
+	// 親から順にsubtree_controlでコントローラーを有効にしないと子のcgroupに設定ファイルが出来ない
+	if err := enableControllers(root, filepath.Dir(c.name), controllers); err != nil {
+		return nil, err
+	}
+
+	exist := isExistDir(c.dir)
+	if exist {
+		if err := checkCgroupUnused(c.dir); err != nil {
+			return nil, fmt.Errorf("cgroup %s: %w", c.name, err)
+		}
+	} else if err := os.Mkdir(c.dir, 0755); err != nil {
+		return nil, err
+	}
+
+	for _, s := range settings {
+		value := s.value
+		if value == "" {
+			// 新しく作ったcgroupは既定値のまま。コントローラーが無効ならファイルが無い
+			if !exist || !isExistFile(filepath.Join(c.dir, s.file)) {
+				continue
+			}
+			value = s.reset
+		}
+		if err := writeCgroupFile(c.dir, s.file, value); err != nil {
+			os.Remove(c.dir)
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// enableControllers マウントポイントからparentまでの各階層でコントローラーを有効にする
+func enableControllers(root, parent string, controllers []string) error {
+	dir := root
+	levels := []string{dir}
+	for _, p := range strings.Split(strings.Trim(parent, "/"), "/") {
+		if p == "" {
+			continue
+		}
+		dir = filepath.Join(dir, p)
+		levels = append(levels, dir)
+	}
+
+	for _, level := range levels {
+		if !isExistDir(level) {
+			if err := os.Mkdir(level, 0755); err != nil {
+				return err
+			}
+		}
+		if len(controllers) == 0 {
+			continue
+		}
+		available, err := os.ReadFile(filepath.Join(level, "cgroup.controllers"))
+		if err != nil {
+			return err
+		}
+		enabled, err := os.ReadFile(filepath.Join(level, "cgroup.subtree_control"))
+		if err != nil {
+			return err
+		}
+		for _, ctrl := range controllers {
+			if containsField(string(enabled), ctrl) {
+				continue
+			}
+			if !containsField(string(available), ctrl) {
+				return fmt.Errorf("cgroup controller %s is not available in %s", ctrl, level)
+			}
+			if err := writeCgroupFile(level, "cgroup.subtree_control", "+"+ctrl); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// containsField スペース区切りの文字列にfieldが含まれるか
+func containsField(s, field string) bool {
+	for _, f := range strings.Fields(s) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCgroupFile cgroupの設定ファイルに書き込む。失敗した時にどのファイルか分かるようにする
+func writeCgroupFile(dir, file, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("write %q to %s: %w", value, filepath.Join(dir, file), err)
+	}
+	return nil
+}
+
+// checkCgroupUnused 前回の起動で残ったcgroupにプロセスや子のcgroupが残っていないか確認する
+func checkCgroupUnused(dir string) error {
+	pids, err := readCgroupProcs(dir)
+	if err != nil {
+		return err
+	}
+	if len(pids) > 0 {
+		return fmt.Errorf("already in use by %d processes", len(pids))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			return fmt.Errorf("has child cgroup %s", e.Name())
+		}
+	}
+	return nil
+}
+
+// readCgroupProcs cgroupに属するPIDを返す
+func readCgroupProcs(dir string) ([]int, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+	pids := []int{}
+	for _, f := range strings.Fields(string(b)) {
+		pid, err := strconv.Atoi(f)
+		if err != nil {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// setCmd clone3(CLONE_INTO_CGROUP)で起動と同時にcgroupに入れる(後から移すとその間にforkした子を取りこぼす)
+// clone3が使えない時は何もせず、attacherでexec直後に止めている間にcgroupに移す
+func (c *cgroup) setCmd(cmd *exec.Cmd) error {
+	if c == nil || !canCloneIntoCgroup() {
+		return nil
+	}
+	fd, err := os.Open(c.dir)
+	if err != nil {
+		return err
+	}
+	c.fd = fd
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(fd.Fd())
+	return nil
+}
+
+// attacher clone3で起動と同時にcgroupに入れられない時に、起動したプロセスをcgroup.procsに書き込んで移す関数を返す
+// startCmdでプログラムが動き出す前に呼ぶので、forkした子を取りこぼさない。移す必要が無ければnilを返す
+func (c *cgroup) attacher() func(pid int) error {
+	if c == nil || c.fd != nil {
+		return nil
+	}
+	return func(pid int) error {
+		return writeCgroupFile(c.dir, "cgroup.procs", strconv.Itoa(pid))
+	}
+}
+
+var (
+	cloneIntoCgroupOnce sync.Once
+	cloneIntoCgroupOK   bool
+)
+
+// canCloneIntoCgroup clone3のCLONE_INTO_CGROUPが使えるか(Linux 5.7以降で、seccomp等でclone3が塞がれていない)
+func canCloneIntoCgroup() bool {
+	cloneIntoCgroupOnce.Do(func() {
+		if !kernelAtLeast(5, 7) {
+			return
+		}
+		// 引数が無ければEINVAL、clone3自体が無いか塞がれていればENOSYSやEPERMになる
+		_, _, errno := unix.RawSyscall(unix.SYS_CLONE3, 0, 0, 0)
+		cloneIntoCgroupOK = errno == unix.EINVAL
+	})
+	return cloneIntoCgroupOK
+}
+
+// kernelAtLeast 動いているカーネルのバージョンがmajor.minor以上か
+func kernelAtLeast(major, minor int) bool {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return false
+	}
+	return parseKernelVersion(unix.ByteSliceToString(uts.Release[:])) >= major<<16|minor
+}
+
+// parseKernelVersion "5.15.0-91-generic"のようなリリース名からmajor<<16|minorを返す
+func parseKernelVersion(release string) int {
+	fields := strings.FieldsFunc(release, func(r rune) bool { return r < '0' || r > '9' })
+	if len(fields) < 2 {
+		return 0
+	}
+	major, _ := strconv.Atoi(fields[0])
+	minor, _ := strconv.Atoi(fields[1])
+	return major<<16 | minor
+}
+
+// destroy cgroupに残ったプロセスを終了させてcgroupを削除する
+func (c *cgroup) destroy() error {
+	if c == nil {
+		return nil
+	}
+	if c.fd != nil {
+		c.fd.Close()
+		c.fd = nil
+	}
+	if err := killCgroupDir(c.dir); err != nil {
+		return err
+	}
+	// プロセスが居なくなってもすぐには消せないことがあるので少し待つ
+	var err error
+	for i := 0; i < 50; i++ {
+		if err = os.Remove(c.dir); err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return err
+}
+
+// KillCgroup cgroup(/proc/<pid>/cgroupと同じ表記のパス)に属する全プロセスをSIGKILLで終了させる
+func KillCgroup(cgpath string) error {
+	root, err := cgroupRoot()
+	if err != nil {
+		return err
+	}
+	return killCgroupDir(filepath.Join(root, cgpath))
+}
+
+// killCgroupDir cgroupに属する全プロセスを終了させ、居なくなるまで待つ
+func killCgroupDir(dir string) error {
+	// 5.14以降はcgroup.killで子孫のcgroupも含めて一括で終了できる
+	useKill := os.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0644) == nil
+
+	for i := 0; i < 100; i++ {
+		pids, err := readCgroupProcs(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if len(pids) == 0 {
+			return nil
+		}
+		// cgroup.killが無ければforkしながら逃げるプロセスも居るので空になるまで繰り返す
+		if !useKill {
+			for _, pid := range pids {
+				syscall.Kill(pid, syscall.SIGKILL)
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return fmt.Errorf("processes remain in cgroup %s", dir)
+}
+
+// cgroup v1のマウントポイント(hybridやv1のみの環境で使用量を読む)
+const cgroupV1Root = "/sys/fs/cgroup"
+
+// getCgroupInfo 指定されたPIDのcgroupを/proc/<pid>/cgroupから取得し、読めればcgroupの使用量も返す
+func getCgroupInfo(pid int) (*CgroupInfo, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return nil, err
+	}
+	ret := parseProcCgroup(string(b))
+
+	// コンテナ内から見た時などマウントポイントとパスが合わないことがあるので、読めなければ0のままにする
+	if root, err := cgroupRoot(); err == nil && ret.V1Paths == nil {
+		dir := filepath.Join(root, ret.Path)
+		ret.MemoryCurrent, _ = readCgroupUint(filepath.Join(dir, "memory.current"))
+		ret.CpuUsageUsec, _ = readCgroupStat(filepath.Join(dir, "cpu.stat"), "usage_usec")
+	} else {
+		if p, ok := ret.V1Paths["memory"]; ok {
+			ret.MemoryCurrent, _ = readCgroupUint(filepath.Join(cgroupV1Root, "memory", p, "memory.usage_in_bytes"))
+		}
+		if p, ok := ret.V1Paths["cpuacct"]; ok {
+			usage, err := readCgroupUint(filepath.Join(cgroupV1Root, "cpuacct", p, "cpuacct.usage"))
+			if err == nil {
+				ret.CpuUsageUsec = usage / 1000
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// readCgroupUint 数値1つだけのcgroupのファイルを読む
+func readCgroupUint(file string) (uint64, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// readCgroupStat cpu.statやmemory.eventsのような"key value"形式のファイルから値を読む
+func readCgroupStat(file, key string) (uint64, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("%s is not found in %s", key, file)
+}
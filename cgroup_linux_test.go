@@ -0,0 +1,172 @@
+package goproc
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartServiceInCgroup(t *testing.T) {
+	root, err := cgroupRoot()
+	if err != nil || os.Geteuid() != 0 {
+		t.Skipf("cgroup v2 is not writable: %v", err)
+	}
+
+	// clone3が使えない時はexec直後にcgroup.procsへ書き込んで移す
+	canCloneIntoCgroup()
+	clone := cloneIntoCgroupOK
+	defer func() { cloneIntoCgroupOK = clone }()
+	cases := []struct {
+		clone bool
+		msg   string
+	}{
+		{clone, "clone3で起動と同時に入れる"},
+		{false, "起動直後にcgroup.procsへ書き込む"},
+	}
+	for _, c := range cases {
+		t.Run(c.msg, func(t *testing.T) {
+			cloneIntoCgroupOK = c.clone
+			param := ProcessParam{Command: "sh", Args: "-c \"sleep 30 & sleep 30\"", Cgroup: &CgroupParam{Name: "goproc-test"}}
+			dir := filepath.Join(root, CgroupPath(param))
+
+			done := make(chan error, 2)
+			go StartService(done, param)
+
+			var pids []int
+			for i := 0; i < 50; i++ {
+				pids, _ = readCgroupProcs(dir)
+				if len(pids) >= 3 {
+					break
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+			if len(pids) < 3 {
+				select {
+				case err := <-done:
+					t.Skipf("can't start in cgroup: %v", err)
+				default:
+				}
+				t.Fatalf("cgroup.procs = %v, Failed", pids)
+			}
+			b, _ := os.ReadFile("/proc/" + strconv.Itoa(pids[0]) + "/cgroup")
+			if !strings.Contains(string(b), "goproc/goproc-test") {
+				t.Errorf("/proc/<pid>/cgroup = %s, Failed", b)
+			}
+
+			// 親から外れたsleepも含めて全部終了させる
+			if err := StopServiceByCgroup(param); err != nil {
+				t.Errorf("StopServiceByCgroup = %s, Failed", err)
+			}
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("StartService didn't finish, Failed")
+			}
+			for i := 0; i < 50 && isExistDir(dir); i++ {
+				time.Sleep(20 * time.Millisecond)
+			}
+			if isExistDir(dir) {
+				t.Errorf("cgroup %s is not removed, Failed", dir)
+			}
+		})
+	}
+}
+
+func TestCreateCgroupReuse(t *testing.T) {
+	if _, err := cgroupRoot(); err != nil || os.Geteuid() != 0 {
+		t.Skipf("cgroup v2 is not writable: %v", err)
+	}
+
+	param := ProcessParam{Cgroup: &CgroupParam{Name: "goproc-reuse", PidsMax: "10"}}
+	c, err := createCgroup(param)
+	if err != nil {
+		t.Skipf("createCgroup = %s", err)
+	}
+	defer c.destroy()
+	if b, _ := os.ReadFile(filepath.Join(c.dir, "pids.max")); strings.TrimSpace(string(b)) != "10" {
+		t.Fatalf("pids.max = %s, Failed", b)
+	}
+
+	// 残っていたcgroupを使う時は前回の値を引き継がない
+	param.Cgroup.PidsMax = ""
+	if _, err := createCgroup(param); err != nil {
+		t.Fatalf("createCgroup(reuse) = %s, Failed", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(c.dir, "pids.max")); strings.TrimSpace(string(b)) != "max" {
+		t.Errorf("pids.max = %s, expect = max, Failed", b)
+	}
+
+	// 子のcgroupが残っていれば使わない
+	if err := os.Mkdir(filepath.Join(c.dir, "child"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filepath.Join(c.dir, "child"))
+	if _, err := createCgroup(param); err == nil {
+		t.Errorf("createCgroup(child) = nil, Failed")
+	}
+}
+
+func TestParseKernelVersion(t *testing.T) {
+	cases := []struct {
+		in     string
+		except int
+	}{
+		{"5.15.0-91-generic", 5<<16 | 15},
+		{"5.7.0", 5<<16 | 7},
+		{"6.1", 6<<16 | 1},
+		{"unknown", 0},
+	}
+	for _, c := range cases {
+		if got := parseKernelVersion(c.in); got != c.except {
+			t.Errorf("parseKernelVersion(%s) = %x, expect = %x, Failed", c.in, got, c.except)
+		}
+	}
+}
+
+func TestParseProcCgroup(t *testing.T) {
+	id := strings.Repeat("0123456789abcdef", 4)
+	cases := []struct {
+		in      string
+		path    string
+		id      string
+		runtime string
+		unit    string
+		slice   string
+		msg     string
+	}{
+		{"0::/system.slice/jetty.service\n", "/system.slice/jetty.service", "", "", "jetty.service", "system.slice", "systemdのサービス"},
+		{"0::/system.slice/docker-" + id + ".scope\n", "/system.slice/docker-" + id + ".scope", id, "docker", "docker-" + id + ".scope", "system.slice", "docker(systemd cgroup driver)"},
+		{"0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-" + id + ".scope\n", "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-" + id + ".scope", id, "containerd", "cri-containerd-" + id + ".scope", "kubepods-burstable-pod1234.slice", "containerd(kubernetes)"},
+		{"0::/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + id + ".scope/container\n", "/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + id + ".scope/container", id, "podman", "libpod-" + id + ".scope", "user.slice", "podman(rootless)の中は末尾がcontainer"},
+		{"12:memory:/docker/" + id + "\n11:name=systemd:/docker/" + id + "\n0::/\n", "/docker/" + id, id, "docker", "", "", "docker(cgroup v1)"},
+		{"4:memory:/kubepods/burstable/pod1234/" + id + "\n1:cpu,cpuacct:/kubepods/burstable/pod1234/" + id + "\n", "/kubepods/burstable/pod1234/" + id, id, "kubernetes", "", "", "kubernetes(cgroup v1)"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.msg, func(t *testing.T) {
+			info := parseProcCgroup(c.in)
+			if info.Path != c.path {
+				t.Errorf("Path = %s, expect = %s, Failed", info.Path, c.path)
+			}
+			if c.id != "" && (info.ContainerID != c.id || info.ContainerRuntime != c.runtime) {
+				t.Errorf("Container = %s(%s), expect = %s(%s), Failed", info.ContainerID, info.ContainerRuntime, c.id, c.runtime)
+			}
+			if info.SystemdUnit != c.unit || info.SystemdSlice != c.slice {
+				t.Errorf("Systemd = %s(%s), expect = %s(%s), Failed", info.SystemdUnit, info.SystemdSlice, c.unit, c.slice)
+			}
+		})
+	}
+}
+
+func TestGetCgroupInfo(t *testing.T) {
+	info, err := getCgroupInfo(os.Getpid())
+	if err != nil {
+		t.Fatalf("getCgroupInfo = %s, Failed", err)
+	}
+	if info.Path == "" && info.V1Paths == nil {
+		t.Errorf("getCgroupInfo = %#v, Failed", info)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+type cgroup struct{}
+
+// createCgroup cgroupはLinuxのみ対応
+func createCgroup(param ProcessParam) (*cgroup, error) {
+	if param.Cgroup == nil {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%w: cgroup", ErrNotSupported)
+}
+
+func (c *cgroup) setCmd(cmd *exec.Cmd) error {
+	return nil
+}
+
+func (c *cgroup) attacher() func(pid int) error {
+	return nil
+}
+
+func (c *cgroup) destroy() error {
+	return nil
+}
+
+// KillCgroup cgroupはLinuxのみ対応
+func KillCgroup(cgpath string) error {
+	return ErrNotSupported
+}
+
+// getCgroupInfo cgroupはLinuxのみ対応
+func getCgroupInfo(pid int) (*CgroupInfo, error) {
+	return nil, ErrNotSupported
+}
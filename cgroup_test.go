@@ -0,0 +1,28 @@
+package goproc_test
+
+import (
+	"testing"
+
+	"github.com/gozuk16/goproc"
+)
+
+func TestCgroupPath(t *testing.T) {
+	cases := []struct {
+		param  goproc.ProcessParam
+		except string
+		msg    string
+	}{
+		{goproc.ProcessParam{Command: "java"}, "", "Cgroupの指定が無ければ空"},
+		{goproc.ProcessParam{Command: "/usr/bin/java", Cgroup: &goproc.CgroupParam{}}, "/goproc/java", "名前が無ければコマンド名"},
+		{goproc.ProcessParam{Args: "/usr/bin/java -jar start.jar", Cgroup: &goproc.CgroupParam{}}, "/goproc/java", "Argsの1つ目をコマンドと見なす"},
+		{goproc.ProcessParam{Command: "java", Cgroup: &goproc.CgroupParam{Name: "jetty", Parent: "services.slice/"}}, "/services.slice/jetty", "親と名前を指定"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.msg, func(t *testing.T) {
+			if p := goproc.CgroupPath(c.param); p != c.except {
+				t.Errorf("CgroupPath = %s, expect = %s, Failed", p, c.except)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package goproc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// 起動するプロセスの資格情報
+type credential struct {
+	Uid    uint32
+	Gid    uint32
+	Groups []uint32
+	// User指定時のみセットされる(HOME等の環境変数に使う)
+	User *user.User
+}
+
+// setCredential ProcessParamのUser/Group/SupplementaryGroupsを解決してcmdに反映する
+func setCredential(cmd *exec.Cmd, param ProcessParam) error {
+	if param.User == "" && param.Group == "" && len(param.SupplementaryGroups) == 0 {
+		return nil
+	}
+
+	cred, err := resolveCredential(param)
+	if err != nil {
+		return err
+	}
+
+	// setuid/setgid/setgroupsはrootでないと失敗するので、起動前に分かりやすいエラーで返す
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("%w: start as uid=%d gid=%d requires root (euid=%d)", ErrNoPrivilege, cred.Uid, cred.Gid, os.Geteuid())
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    cred.Uid,
+		Gid:    cred.Gid,
+		Groups: cred.Groups,
+	}
+
+	// ユーザーを切り替える時はHOME/USER/LOGNAMEも合わせないと起動元のユーザーのままになる
+	if cred.User != nil {
+		env := cmd.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		// passwdに無いUIDはホームが無いので、起動元のHOMEを漏らさず/にする
+		home := cred.User.HomeDir
+		if home == "" {
+			home = "/"
+		}
+		env = setEnvValue(env, "HOME", home)
+		env = setEnvValue(env, "USER", cred.User.Username)
+		env = setEnvValue(env, "LOGNAME", cred.User.Username)
+		cmd.Env = env
+	}
+
+	return nil
+}
+
+// resolveCredential ユーザー名・グループ名(またはID)を解決する
+func resolveCredential(param ProcessParam) (*credential, error) {
+	cred := &credential{
+		Uid: uint32(os.Getuid()),
+		Gid: uint32(os.Getgid()),
+	}
+
+	if param.User != "" {
+		u, err := lookupUser(param.User)
+		if err != nil {
+			return nil, err
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uid %q of user %s", u.Uid, param.User)
+		}
+		gid, err := strconv.ParseUint(u.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gid %q of user %s", u.Gid, param.User)
+		}
+		cred.Uid = uint32(uid)
+		cred.Gid = uint32(gid)
+		cred.User = u
+
+		// 補助グループの指定が無ければloginと同じくユーザーの所属グループにする
+		if len(param.SupplementaryGroups) == 0 {
+			gids, err := u.GroupIds()
+			if err != nil {
+				return nil, fmt.Errorf("get groups of user %s: %w", param.User, err)
+			}
+			for _, g := range gids {
+				id, err := strconv.ParseUint(g, 10, 32)
+				if err != nil {
+					continue
+				}
+				cred.Groups = append(cred.Groups, uint32(id))
+			}
+		}
+	}
+
+	if param.Group != "" {
+		gid, err := lookupGroupId(param.Group)
+		if err != nil {
+			return nil, err
+		}
+		cred.Gid = gid
+	}
+
+	for _, g := range param.SupplementaryGroups {
+		gid, err := lookupGroupId(g)
+		if err != nil {
+			return nil, err
+		}
+		cred.Groups = append(cred.Groups, gid)
+	}
+
+	return cred, nil
+}
+
+// lookupUser ユーザー名かUIDでユーザーを探す。passwdに無いUIDは数値のまま使う
+func lookupUser(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if err == nil {
+		return u, nil
+	}
+	if _, perr := strconv.ParseUint(name, 10, 32); perr != nil {
+		return nil, err
+	}
+	u, err = user.LookupId(name)
+	if err == nil {
+		return u, nil
+	}
+	return &user.User{Uid: name, Gid: name, Username: name}, nil
+}
+
+// lookupGroupId グループ名かGIDでGIDを返す。groupに無いGIDは数値のまま使う
+func lookupGroupId(name string) (uint32, error) {
+	g, err := user.LookupGroup(name)
+	if err == nil {
+		name = g.Gid
+	}
+	gid, perr := strconv.ParseUint(name, 10, 32)
+	if perr != nil {
+		return 0, err
+	}
+	return uint32(gid), nil
+}
@@ -0,0 +1,83 @@
+package goproc
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestResolveCredential(t *testing.T) {
+	cases := []struct {
+		param  ProcessParam
+		uid    uint32
+		gid    uint32
+		except bool
+		msg    string
+	}{
+		{ProcessParam{User: "root"}, 0, 0, true, "ユーザー名で指定"},
+		{ProcessParam{User: "0"}, 0, 0, true, "UIDで指定"},
+		{ProcessParam{User: "0", Group: "0"}, 0, 0, true, "UIDとGIDで指定"},
+		{ProcessParam{User: "65000"}, 65000, 65000, true, "passwdに無いUIDは数値のまま使う"},
+		{ProcessParam{User: "goproc-no-such-user"}, 0, 0, false, "存在しないユーザーはエラー"},
+		{ProcessParam{Group: "goproc-no-such-group"}, 0, 0, false, "存在しないグループはエラー"},
+		{ProcessParam{User: "0", SupplementaryGroups: []string{"goproc-no-such-group"}}, 0, 0, false, "存在しない補助グループはエラー"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.msg, func(t *testing.T) {
+			cred, err := resolveCredential(c.param)
+			if !c.except {
+				if err == nil {
+					t.Errorf("resolveCredential nothing err, Failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveCredential = %s, Failed", err)
+			}
+			if cred.Uid != c.uid || cred.Gid != c.gid {
+				t.Errorf("resolveCredential = uid:%d gid:%d, expect uid:%d gid:%d, Failed", cred.Uid, cred.Gid, c.uid, c.gid)
+			}
+		})
+	}
+}
+
+func TestSetCredential(t *testing.T) {
+	cmd := exec.Command("id")
+	err := setCredential(cmd, ProcessParam{User: "root"})
+	if os.Geteuid() != 0 {
+		if !errors.Is(err, ErrNoPrivilege) {
+			t.Errorf("setCredential = %v, expect ErrNoPrivilege, Failed", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("setCredential = %s, Failed", err)
+	}
+	if cmd.SysProcAttr == nil || cmd.SysProcAttr.Credential == nil {
+		t.Fatalf("SysProcAttr.Credential is not set, Failed")
+	}
+	env := EnvToMap(cmd.Env)
+	if env["USER"] != "root" || env["LOGNAME"] != "root" || !strings.HasPrefix(env["HOME"], "/") {
+		t.Errorf("env = USER:%s LOGNAME:%s HOME:%s, Failed", env["USER"], env["LOGNAME"], env["HOME"])
+	}
+
+	// passwdに無いUIDは起動元のHOMEを引き継がない
+	cmd = exec.Command("id")
+	cmd.Env = []string{"HOME=/root"}
+	if err := setCredential(cmd, ProcessParam{User: "65000"}); err != nil {
+		t.Fatalf("setCredential = %s, Failed", err)
+	}
+	env = EnvToMap(cmd.Env)
+	if env["HOME"] != "/" || env["USER"] != "65000" {
+		t.Errorf("env = USER:%s HOME:%s, expect USER:65000 HOME:/, Failed", env["USER"], env["HOME"])
+	}
+
+	// 指定が無ければ何もしない
+	cmd = exec.Command("id")
+	if err := setCredential(cmd, ProcessParam{}); err != nil || cmd.SysProcAttr != nil {
+		t.Errorf("setCredential = %v, Failed", err)
+	}
+}
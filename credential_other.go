@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// setCredential ユーザー・グループの切り替えはLinuxのみ対応
+func setCredential(cmd *exec.Cmd, param ProcessParam) error {
+	if param.User == "" && param.Group == "" && len(param.SupplementaryGroups) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: user or group", ErrNotSupported)
+}
@@ -0,0 +1,135 @@
+package goproc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// 環境変数の差分
+type EnvDiff struct {
+	Added   map[string]string    `json:"added"`
+	Removed map[string]string    `json:"removed"`
+	Changed map[string]EnvChange `json:"changed"`
+}
+
+// 値が変わった環境変数の変更前後
+type EnvChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// EnvToMap KEY=VALUE形式の環境変数をmapに変換する
+func EnvToMap(envs []string) map[string]string {
+	ret := map[string]string{}
+	for _, e := range envs {
+		key, value, ok := cutEnv(e)
+		if !ok {
+			// KEY=VALUE になってない場合はスキップ
+			continue
+		}
+		ret[key] = value
+	}
+	return ret
+}
+
+// cutEnv 環境変数をKEYとVALUEに分割する。Winの"=C:=C:\"のように=で始まる変数も考慮する
+func cutEnv(env string) (string, string, bool) {
+	if env == "" {
+		return "", "", false
+	}
+	i := strings.Index(env[1:], "=")
+	if i < 0 {
+		return "", "", false
+	}
+	return env[:i+1], env[i+2:], true
+}
+
+// setEnvValue KEY=VALUE形式の環境変数にkeyがあれば置き換え、無ければ追加して返す
+func setEnvValue(envs []string, key, value string) []string {
+	ret := []string{}
+	for _, e := range envs {
+		if k, _, ok := cutEnv(e); ok && k == key {
+			continue
+		}
+		ret = append(ret, e)
+	}
+	return append(ret, key+"="+value)
+}
+
+// GetEnvMap 指定されたPIDの環境変数をmapで返す
+func GetEnvMap(pid int) (map[string]string, error) {
+	// 渡されたpidがマイナス、0、1の時はエラーで返す(そうじゃないとPanicになる)
+	if pid <= 1 {
+		return nil, fmt.Errorf("Don't get process, when pid is %d", pid)
+	}
+
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return nil, err
+	}
+
+	envs, err := GetEnviron(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return EnvToMap(envs), nil
+}
+
+// DiffEnv pidの環境変数を基準にotherPidの環境変数との差分を返す
+func DiffEnv(pid, otherPid int) (*EnvDiff, error) {
+	base, err := GetEnvMap(pid)
+	if err != nil {
+		return nil, err
+	}
+	other, err := GetEnvMap(otherPid)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffEnvMap(base, other), nil
+}
+
+// DiffEnvWithParent 親プロセスの環境変数を基準に指定されたPIDの環境変数との差分を返す
+func DiffEnvWithParent(pid int) (*EnvDiff, error) {
+	if pid <= 1 {
+		return nil, fmt.Errorf("Don't get process, when pid is %d", pid)
+	}
+
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return nil, err
+	}
+	ppid, err := p.Ppid()
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffEnv(int(ppid), pid)
+}
+
+// DiffEnvMap baseを基準にotherとの差分を返す。保存しておいたベースラインとの比較にも使う
+func DiffEnvMap(base, other map[string]string) *EnvDiff {
+	ret := &EnvDiff{
+		Added:   map[string]string{},
+		Removed: map[string]string{},
+		Changed: map[string]EnvChange{},
+	}
+
+	for k, v := range other {
+		if bv, ok := base[k]; !ok {
+			ret.Added[k] = v
+		} else if bv != v {
+			ret.Changed[k] = EnvChange{Old: bv, New: v}
+		}
+	}
+	for k, v := range base {
+		if _, ok := other[k]; !ok {
+			ret.Removed[k] = v
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,75 @@
+package goproc_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gozuk16/goproc"
+)
+
+func TestEnvToMap(t *testing.T) {
+	cases := []struct {
+		in     []string
+		key    string
+		except string
+		msg    string
+	}{
+		{[]string{"SHELL=/bin/zsh"}, "SHELL", "/bin/zsh", "一般的な環境変数"},
+		{[]string{"PERL_MM_OPT=INSTALL_BASE=/Users/gozu/perl5"}, "PERL_MM_OPT", "INSTALL_BASE=/Users/gozu/perl5", "=が入った環境変数"},
+		{[]string{"EMPTY="}, "EMPTY", "", "Valueがない環境変数"},
+		{[]string{"=C:=C:\\Users"}, "=C:", "C:\\Users", "=で始まる環境変数(Win)"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.msg, func(t *testing.T) {
+			m := goproc.EnvToMap(c.in)
+			if v, ok := m[c.key]; !ok || v != c.except {
+				t.Errorf("EnvToMap = %#v, expect %s=%s, Failed", m, c.key, c.except)
+			}
+		})
+	}
+
+	if m := goproc.EnvToMap([]string{"", "NOVALUE"}); len(m) != 0 {
+		t.Errorf("EnvToMap = %#v, expect empty, Failed", m)
+	}
+}
+
+func TestDiffEnvMap(t *testing.T) {
+	base := map[string]string{"PATH": "/usr/bin", "HOME": "/root", "LANG": "C"}
+	other := map[string]string{"PATH": "/opt/java/bin:/usr/bin", "HOME": "/root", "JAVA_HOME": "/opt/java"}
+
+	d := goproc.DiffEnvMap(base, other)
+	if len(d.Added) != 1 || d.Added["JAVA_HOME"] != "/opt/java" {
+		t.Errorf("Added = %#v, Failed", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed["LANG"] != "C" {
+		t.Errorf("Removed = %#v, Failed", d.Removed)
+	}
+	if len(d.Changed) != 1 || d.Changed["PATH"].Old != "/usr/bin" || d.Changed["PATH"].New != "/opt/java/bin:/usr/bin" {
+		t.Errorf("Changed = %#v, Failed", d.Changed)
+	}
+}
+
+func TestDiffEnv(t *testing.T) {
+	cmd := goproc.StartSleep(t, "5", "GOPROC_TEST_ENV=1")
+
+	d, err := goproc.DiffEnv(os.Getpid(), cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("DiffEnv = %s, Failed", err)
+	}
+	if d.Added["GOPROC_TEST_ENV"] != "1" {
+		t.Errorf("Added = %#v, Failed", d.Added)
+	}
+
+	d, err = goproc.DiffEnvWithParent(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("DiffEnvWithParent = %s, Failed", err)
+	}
+	if d.Added["GOPROC_TEST_ENV"] != "1" {
+		t.Errorf("Added = %#v, Failed", d.Added)
+	}
+
+	if _, err := goproc.DiffEnv(0, cmd.Process.Pid); err == nil {
+		t.Errorf("DiffEnv nothing err, Failed")
+	}
+}
@@ -0,0 +1,161 @@
+package goproc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// startCmd cmdを起動し、プログラムが動き出す前にpreparesを実行する
+// ptraceでexec直後(最初の命令の前)に止めてから適用するので、rlimit等はプログラムの起動時から有効になる
+// setupは起動するスレッドで起動前に呼ばれ、スレッドに設定したnice等はforkした子プロセスに引き継がれる
+// setupで変えたスレッドは他のgoroutineに戻せないので、releaseが呼ばれるまで持ち続けてから捨てる
+// (pdeathsigはforkしたスレッドが終了した時にも送られるので、子プロセスが終わるまで終了させられない)
+// releaseはcmd.Wait()の後に呼ぶ。preparesやsetupが失敗したらプロセスを終了させてエラーを返す
+func startCmd(cmd *exec.Cmd, setup func() error, prepares ...func(pid int) error) (func(), error) {
+	if setup == nil {
+		return func() {}, startStopped(cmd, prepares)
+	}
+
+	started := make(chan error, 1)
+	exited := make(chan struct{})
+	goLockedThread(func() {
+		if err := setup(); err != nil {
+			started <- err
+			return
+		}
+		err := startStopped(cmd, prepares)
+		started <- err
+		if err == nil {
+			<-exited
+		}
+	})
+	if err := <-started; err != nil {
+		return func() {}, err
+	}
+	return func() { close(exited) }, nil
+}
+
+// goLockedThread fnをメインスレッド以外のスレッドに固定したgoroutineで動かす
+// fnが終わってもUnlockOSThreadしないので、スレッドは他のgoroutineに戻らずに捨てられる
+// メインスレッドは終了できずに残り、そのniceは/proc/<pid>/statにも出るので使わない
+func goLockedThread(fn func()) {
+	go func() {
+		runtime.LockOSThread()
+		if unix.Gettid() != unix.Getpid() {
+			fn()
+			return
+		}
+		// メインスレッドをこのgoroutineで塞いでいる間に起動すれば、別のスレッドで動く
+		locked := make(chan struct{})
+		go func() {
+			runtime.LockOSThread()
+			close(locked)
+			fn()
+		}()
+		<-locked
+		runtime.UnlockOSThread()
+	}()
+}
+
+// startStopped cmdをexec直後で止めた状態で起動し、preparesを実行してから動かす
+// preparesが無ければptraceを使わずにそのまま起動する。ptraceが使えなければErrNotSupportedかErrNoPrivilegeを返す
+func startStopped(cmd *exec.Cmd, prepares []func(pid int) error) error {
+	if len(prepares) == 0 {
+		return cmd.Start()
+	}
+	if err := checkPtraceExec(cmd.Path); err != nil {
+		return err
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Ptrace = true
+
+	// ptraceのトレーサーはforkしたスレッドになるので、デタッチまで同じスレッドで行う
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := cmd.Start(); err != nil {
+		// PTRACE_TRACEMEがseccompやコンテナの設定で禁止されている
+		if errors.Is(err, unix.EPERM) {
+			return fmt.Errorf("%w: ptrace to stop %s before exec: %v", ErrNoPrivilege, cmd.Path, err)
+		}
+		if errors.Is(err, unix.ENOSYS) {
+			return fmt.Errorf("%w: ptrace to stop %s before exec: %v", ErrNotSupported, cmd.Path, err)
+		}
+		return err
+	}
+	pid := cmd.Process.Pid
+	err := waitExecStop(pid)
+	for _, prepare := range prepares {
+		if err != nil {
+			break
+		}
+		err = prepare(pid)
+	}
+	if err == nil {
+		if err = unix.PtraceDetach(pid); err != nil {
+			err = fmt.Errorf("ptrace detach %d: %w", pid, err)
+		}
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	return nil
+}
+
+// checkPtraceExec exec直後にptraceで止めて起動できるかを確認する
+// Yamaのptrace_scopeが3ならptraceは使えない。トレース中のexecではsetuid・setgidやファイルケーパビリティで昇格しないので、
+// 黙って権限の無いまま動かさないようにroot以外では起動しない
+func checkPtraceExec(path string) error {
+	if b, err := os.ReadFile("/proc/sys/kernel/yama/ptrace_scope"); err == nil && strings.TrimSpace(string(b)) == "3" {
+		return fmt.Errorf("%w: ptrace to stop %s before exec is disabled by kernel.yama.ptrace_scope=3", ErrNotSupported, path)
+	}
+	if os.Geteuid() == 0 {
+		return nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		// 起動できないエラーはcmd.Start()で返す
+		return nil
+	}
+	if fi.Mode()&(os.ModeSetuid|os.ModeSetgid) != 0 {
+		return fmt.Errorf("%w: %s is setuid/setgid and does not gain privilege when started under ptrace", ErrNotSupported, path)
+	}
+	if _, err := unix.Getxattr(path, "security.capability", nil); err == nil {
+		return fmt.Errorf("%w: %s has file capabilities and does not gain them when started under ptrace", ErrNotSupported, path)
+	}
+	return nil
+}
+
+// waitExecStop execした直後のSIGTRAPで止まるのを待つ。それより前に届いたシグナルはそのまま渡す
+func waitExecStop(pid int) error {
+	for {
+		var status unix.WaitStatus
+		_, err := unix.Wait4(pid, &status, 0, nil)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("wait exec of %d: %w", pid, err)
+		}
+		if !status.Stopped() {
+			return fmt.Errorf("process %d exited before exec", pid)
+		}
+		if status.StopSignal() == unix.SIGTRAP {
+			return nil
+		}
+		if err := unix.PtraceCont(pid, int(status.StopSignal())); err != nil {
+			return fmt.Errorf("ptrace cont %d: %w", pid, err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"os/exec"
+)
+
+// startCmd setupを実行してからcmdを起動し、preparesを実行する。preparesが失敗したらプロセスを終了させてエラーを返す
+// exec前に止める方法が無いので、Linux以外では起動直後に実行する
+func startCmd(cmd *exec.Cmd, setup func() error, prepares ...func(pid int) error) (func(), error) {
+	release := func() {}
+	if setup != nil {
+		if err := setup(); err != nil {
+			return release, err
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		return release, err
+	}
+	for _, prepare := range prepares {
+		if err := prepare(cmd.Process.Pid); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return release, err
+		}
+	}
+	return release, nil
+}
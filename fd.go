@@ -0,0 +1,27 @@
+package goproc
+
+import (
+	"fmt"
+)
+
+// オープンしているファイルディスクリプタの情報
+type OpenFile struct {
+	Fd int `json:"fd"`
+	// file, socket, pipe, anon_inode, eventfd等
+	Type string `json:"type"`
+	// ファイルのパスか、socket:[inode]のようなリンク先
+	Path string `json:"path"`
+	// O_RDWR|O_CLOEXECのようなオープン時のフラグ(Linuxのみ)
+	Flags string `json:"flags"`
+	// 読み書きの位置(Linuxのみ)
+	Pos int64 `json:"pos"`
+}
+
+// GetOpenFiles 指定されたPIDがオープンしているファイルディスクリプタの一覧を返す
+func GetOpenFiles(pid int) ([]OpenFile, error) {
+	// 渡されたpidがマイナス、0、1の時はエラーで返す(そうじゃないとPanicになる)
+	if pid <= 1 {
+		return nil, fmt.Errorf("Don't get process, when pid is %d", pid)
+	}
+	return getOpenFiles(pid)
+}
@@ -0,0 +1,118 @@
+package goproc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// オープン時のフラグ名(アクセスモード以外)
+var openFlagNames = []struct {
+	flag int
+	name string
+}{
+	{unix.O_APPEND, "O_APPEND"},
+	{unix.O_NONBLOCK, "O_NONBLOCK"},
+	{unix.O_SYNC, "O_SYNC"},
+	{unix.O_DSYNC, "O_DSYNC"},
+	{unix.O_DIRECT, "O_DIRECT"},
+	{unix.O_NOATIME, "O_NOATIME"},
+	{unix.O_PATH, "O_PATH"},
+	{unix.O_CLOEXEC, "O_CLOEXEC"},
+}
+
+// getOpenFiles /proc/<pid>/fdと/proc/<pid>/fdinfoからファイルディスクリプタの一覧を作る
+func getOpenFiles(pid int) ([]OpenFile, error) {
+	dir := fmt.Sprintf("/proc/%d/fd", pid)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []OpenFile{}
+	for _, e := range entries {
+		fd, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		// 一覧を取ってから閉じられたものはスキップする
+		target, err := os.Readlink(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		f := OpenFile{Fd: fd, Type: fdType(target), Path: target}
+		if info, err := os.ReadFile(fmt.Sprintf("/proc/%d/fdinfo/%d", pid, fd)); err == nil {
+			f.Pos, f.Flags = parseFdinfo(string(info))
+		}
+		ret = append(ret, f)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Fd < ret[j].Fd })
+
+	return ret, nil
+}
+
+// fdType /proc/<pid>/fd/Nのリンク先から種類を判定する
+func fdType(target string) string {
+	switch {
+	case strings.HasPrefix(target, "socket:"):
+		return "socket"
+	case strings.HasPrefix(target, "pipe:"):
+		return "pipe"
+	case target == "anon_inode:[eventfd]":
+		return "eventfd"
+	case strings.HasPrefix(target, "anon_inode:"):
+		return "anon_inode"
+	}
+	return "file"
+}
+
+// parseFdinfo /proc/<pid>/fdinfo/Nから位置とフラグを取り出す
+func parseFdinfo(info string) (int64, string) {
+	var pos int64
+	var flags string
+	for _, line := range strings.Split(info, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "pos":
+			pos, _ = strconv.ParseInt(value, 10, 64)
+		case "flags":
+			if n, err := strconv.ParseUint(value, 8, 64); err == nil {
+				flags = openFlagsString(int(n))
+			}
+		}
+	}
+	return pos, flags
+}
+
+// openFlagsString オープン時のフラグをO_RDWR|O_CLOEXECのような文字列にする
+func openFlagsString(flags int) string {
+	names := []string{}
+	switch flags & unix.O_ACCMODE {
+	case unix.O_RDONLY:
+		names = append(names, "O_RDONLY")
+	case unix.O_WRONLY:
+		names = append(names, "O_WRONLY")
+	case unix.O_RDWR:
+		names = append(names, "O_RDWR")
+	}
+	for _, f := range openFlagNames {
+		if flags&f.flag != f.flag {
+			continue
+		}
+		// O_SYNCはO_DSYNCを含むので両方は出さない
+		if f.flag == unix.O_DSYNC && flags&unix.O_SYNC == unix.O_SYNC {
+			continue
+		}
+		names = append(names, f.name)
+	}
+	return strings.Join(names, "|")
+}
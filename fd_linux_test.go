@@ -0,0 +1,79 @@
+package goproc
+
+import (
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestFdType(t *testing.T) {
+	cases := []struct {
+		in     string
+		except string
+	}{
+		{"/var/log/jetty.log", "file"},
+		{"socket:[16767]", "socket"},
+		{"pipe:[16767]", "pipe"},
+		{"anon_inode:[eventfd]", "eventfd"},
+		{"anon_inode:[eventpoll]", "anon_inode"},
+		{"anon_inode:inotify", "anon_inode"},
+	}
+	for _, c := range cases {
+		if typ := fdType(c.in); typ != c.except {
+			t.Errorf("fdType(%s) = %s, expect = %s, Failed", c.in, typ, c.except)
+		}
+	}
+}
+
+func TestParseFdinfo(t *testing.T) {
+	pos, flags := parseFdinfo("pos:\t1234\nflags:\t06112002\nmnt_id:\t25\n")
+	if pos != 1234 || flags != "O_RDWR|O_APPEND|O_SYNC|O_CLOEXEC" {
+		t.Errorf("parseFdinfo = %d, %s, Failed", pos, flags)
+	}
+}
+
+func TestGetOpenFiles(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString("goproc")
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	efd, err := unix.Eventfd(0, unix.EFD_CLOEXEC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(efd)
+
+	files, err := GetOpenFiles(os.Getpid())
+	if err != nil {
+		t.Fatalf("GetOpenFiles = %s, Failed", err)
+	}
+	found := map[string]bool{}
+	for _, of := range files {
+		switch {
+		case of.Fd == int(f.Fd()):
+			found["file"] = of.Type == "file" && of.Path == f.Name() && of.Pos == 6 && of.Flags == "O_RDWR|O_CLOEXEC"
+		case of.Fd == int(r.Fd()):
+			found["pipe"] = of.Type == "pipe"
+		case of.Fd == efd:
+			found["eventfd"] = of.Type == "eventfd"
+		}
+	}
+	for _, k := range []string{"file", "pipe", "eventfd"} {
+		if !found[k] {
+			t.Errorf("GetOpenFiles %s is not found in %#v, Failed", k, files)
+		}
+	}
+
+	if _, err := GetOpenFiles(1); err == nil {
+		t.Errorf("GetOpenFiles nothing err, Failed")
+	}
+}
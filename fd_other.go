@@ -0,0 +1,25 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// getOpenFiles gopsutilで取れる範囲(通常のファイルのみ)で一覧を作る
+func getOpenFiles(pid int) ([]OpenFile, error) {
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return nil, err
+	}
+	files, err := p.OpenFiles()
+	if err != nil {
+		return nil, notSupported(err)
+	}
+	ret := []OpenFile{}
+	for _, f := range files {
+		ret = append(ret, OpenFile{Fd: int(f.Fd), Type: "file", Path: f.Path})
+	}
+	return ret, nil
+}
@@ -0,0 +1,144 @@
+package goproc
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// プロセス検索の条件。ゼロ値の項目は条件にしない(全項目のANDになる)
+type Filter struct {
+	// プロセス名の正規表現
+	NameRegex string `json:"nameRegex"`
+	// コマンドライン(引数を含む)に含まれる文字列。pgrep -fと同じ
+	CmdlineContains string `json:"cmdlineContains"`
+	// 実行ファイルのフルパス
+	ExePath string `json:"exePath"`
+	// 実ユーザーか実効ユーザーの名前またはUID
+	User string `json:"user"`
+	// カレントディレクトリ
+	Cwd string `json:"cwd"`
+	// 親プロセスのPID
+	ParentPid int `json:"parentPid"`
+	// 実グループか実効グループの名前またはGID
+	Group    string `json:"group"`
+	Pgid     int    `json:"pgid"`
+	Sid      int    `json:"sid"`
+	Terminal string `json:"terminal"`
+}
+
+// FindProcesses 条件に合うプロセスを全プロセスから探して返す
+// CPU使用率は見つかったプロセスをまとめて1秒計測する
+func FindProcesses(f Filter) (Processes, error) {
+	pids, err := FindPids(f)
+	if err != nil {
+		return nil, err
+	}
+	return primeSampler(pids).GetProcesses(pids)
+}
+
+// FindProcesses FindProcessesと同じ。CPU使用率と1秒あたりのI/Oは前回呼び出した時からの値になる
+func (s *Sampler) FindProcesses(f Filter) (Processes, error) {
+	pids, err := FindPids(f)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetProcesses(pids)
+}
+
+// FindPids 条件に合うプロセスのPIDを返す
+// 取得が軽い項目から順に判定して、合わなければそのプロセスの残りは取得しない
+func FindPids(f Filter) ([]int, error) {
+	var re *regexp.Regexp
+	if f.NameRegex != "" {
+		var err error
+		re, err = regexp.Compile(f.NameRegex)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pids, err := process.Pids()
+	if err != nil {
+		return nil, err
+	}
+	ret := []int{}
+	for _, pid := range pids {
+		if pid <= 0 {
+			continue
+		}
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			continue
+		}
+		if f.match(p, re) {
+			ret = append(ret, int(pid))
+		}
+	}
+	return ret, nil
+}
+
+// match 取得できない項目に条件がある場合は合わないとする
+func (f Filter) match(p *process.Process, re *regexp.Regexp) bool {
+	if f.ParentPid != 0 {
+		ppid, err := p.Ppid()
+		if err != nil || int(ppid) != f.ParentPid {
+			return false
+		}
+	}
+	if re != nil {
+		name, err := p.Name()
+		if err != nil || !re.MatchString(name) {
+			return false
+		}
+	}
+	if f.CmdlineContains != "" {
+		cmdline, err := p.Cmdline()
+		if err != nil || !strings.Contains(cmdline, f.CmdlineContains) {
+			return false
+		}
+	}
+	if f.ExePath != "" {
+		exe, err := p.Exe()
+		if err != nil || filepath.Clean(exe) != filepath.Clean(f.ExePath) {
+			return false
+		}
+	}
+	if f.Cwd != "" {
+		cwd, err := p.Cwd()
+		if err != nil || filepath.Clean(cwd) != filepath.Clean(f.Cwd) {
+			return false
+		}
+	}
+
+	owner := f.ownerFilter()
+	if owner == (OwnerFilter{}) {
+		return true
+	}
+	ret := Process{}
+	if owner.User != "" || owner.Group != "" {
+		o, err := getOwner(p)
+		if err != nil {
+			return false
+		}
+		ret.Owner = o
+	}
+	if owner.Pgid != 0 || owner.Sid != 0 || owner.Terminal != "" {
+		var err error
+		ret.Pgid, ret.Sid, ret.TtyNr, err = getSession(int(p.Pid))
+		if err != nil {
+			return false
+		}
+		if owner.Terminal != "" && ret.TtyNr != 0 {
+			ret.Terminal, _ = p.Terminal()
+		}
+	}
+	return owner.Match(&ret)
+}
+
+// ownerFilter 所有者・セッションの条件を取り出す
+func (f Filter) ownerFilter() OwnerFilter {
+	return OwnerFilter{User: f.User, Group: f.Group, Pgid: f.Pgid, Sid: f.Sid, Terminal: f.Terminal}
+}
@@ -0,0 +1,48 @@
+package goproc
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestFindProcesses(t *testing.T) {
+	cmd := startSleep(t, "31.4159")
+
+	cases := []struct {
+		in     Filter
+		except int
+		msg    string
+	}{
+		{Filter{CmdlineContains: "sleep 31.4159", ParentPid: os.Getpid()}, 1, "コマンドラインと親PIDで探す"},
+		{Filter{NameRegex: "^sle+p$", ParentPid: os.Getpid()}, 1, "名前と親PIDで探す"},
+		{Filter{NameRegex: "^sleep$", ParentPid: os.Getpid(), User: "-1"}, 0, "ユーザーが合わない"},
+		{Filter{ExePath: "/nonexistent/sleep", ParentPid: os.Getpid()}, 0, "実行ファイルが合わない"},
+	}
+	for _, c := range cases {
+		t.Run(c.msg, func(t *testing.T) {
+			pids, err := FindPids(c.in)
+			if err != nil {
+				t.Fatalf("FindPids = %s, Failed", err)
+			}
+			if len(pids) != c.except {
+				t.Errorf("FindPids = %v, expect = %d, Failed", pids, c.except)
+			}
+			if c.except == 1 && pids[0] != cmd.Process.Pid {
+				t.Errorf("FindPids = %v, expect = %d, Failed", pids, cmd.Process.Pid)
+			}
+		})
+	}
+
+	ps, err := FindProcesses(Filter{CmdlineContains: "sleep 31.4159", ParentPid: os.Getpid(), User: strconv.Itoa(os.Getuid())})
+	if err != nil {
+		t.Fatalf("FindProcesses = %s, Failed", err)
+	}
+	if len(ps) != 1 || ps[0].Pid != cmd.Process.Pid || ps[0].Ppid != os.Getpid() {
+		t.Errorf("FindProcesses = %d processes, Failed", len(ps))
+	}
+
+	if _, err := FindPids(Filter{NameRegex: "("}); err == nil {
+		t.Error("FindPids(invalid regex) = nil, Failed")
+	}
+}
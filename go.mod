@@ -1,9 +1,17 @@
 module github.com/gozuk16/goproc
 
-go 1.16
+go 1.20
 
 require (
 	github.com/inhies/go-bytesize v0.0.0-20210819104631-275770b98743
 	github.com/mattn/go-shellwords v1.0.12
 	github.com/shirou/gopsutil/v3 v3.21.7
+	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c
+)
+
+require (
+	github.com/StackExchange/wmi v1.2.1 // indirect
+	github.com/go-ole/go-ole v1.2.5 // indirect
+	github.com/tklauser/go-sysconf v0.3.7 // indirect
+	github.com/tklauser/numcpus v0.2.3 // indirect
 )
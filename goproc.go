@@ -32,32 +32,72 @@ type ChildrenProcess struct {
 
 // プロセス情報
 type Process struct {
-	Name          string            `json:"name"`
-	CpuPercent    float64           `json:"cpuPercent"`
-	CpuTotal      float64           `json:"cpuTotal"`
-	CpuUser       float64           `json:"cpuUser"`
-	CpuSystem     float64           `json:"cpuSystem"`
-	CpuIdle       float64           `json:"cpuIdle"`
-	CpuIowait     float64           `json:"cpuIowait"`
-	Vms           string            `json:"vms"`
-	Rss           string            `json:"rss"`
-	Swap          string            `json:"swap"`
-	Cmdline       string            `json:"cmdline"`
-	Exe           string            `json:"exe"`
-	Cwd           string            `json:"cwd"`
-	Env           []string          `json:"env"`
-	CreateTime    string            `json:"createTime"`
-	Exist         bool              `json:"exist"`
-	Status        string            `json:"status"`
-	Pid           int               `json:"pid"`
-	Ppid          int               `json:"ppid"`
-	Children      []ChildrenProcess `json:"children"`
-	SumCpuPercent float64           `json:"sumCpuPercent"`
-	SumRss        string            `json:"sumRss"`
+	Name           string            `json:"name"`
+	CpuPercent     float64           `json:"cpuPercent"`
+	CpuTotal       float64           `json:"cpuTotal"`
+	CpuUser        float64           `json:"cpuUser"`
+	CpuSystem      float64           `json:"cpuSystem"`
+	CpuIdle        float64           `json:"cpuIdle"`
+	CpuIowait      float64           `json:"cpuIowait"`
+	Vms            string            `json:"vms"`
+	Rss            string            `json:"rss"`
+	Swap           string            `json:"swap"`
+	MemPercent     float64           `json:"memPercent"`
+	Cmdline        string            `json:"cmdline"`
+	Exe            string            `json:"exe"`
+	Cwd            string            `json:"cwd"`
+	Env            []string          `json:"env"`
+	EnvMap         map[string]string `json:"envMap"`
+	Labels         map[string]string `json:"labels"`
+	Limits         map[string]Limit  `json:"limits"`
+	NumThreads     int               `json:"numThreads"`
+	NumFDs         int               `json:"numFds"`
+	NofileLimit    RlimitValue       `json:"nofileLimit"`
+	ListeningPorts []ListeningPort   `json:"listeningPorts"`
+	Cgroup         *CgroupInfo       `json:"cgroup"`
+	OomScore       int               `json:"oomScore"`
+	OomScoreAdj    int               `json:"oomScoreAdj"`
+	Nice           int               `json:"nice"`
+	SchedPolicy    string            `json:"schedPolicy"`
+	CpusAllowed    []int             `json:"cpusAllowed"`
+	StartTime      time.Time         `json:"startTime"`
+	Uptime         time.Duration     `json:"uptime"`
+	CreateTime     string            `json:"createTime,omitempty"`
+	Exist          bool              `json:"exist"`
+	Status         string            `json:"status"`
+	SchedStats     *SchedStats       `json:"schedStats"`
+	Pid            int               `json:"pid"`
+	Ppid           int               `json:"ppid"`
+	Owner          *Owner            `json:"owner"`
+	Pgid           int               `json:"pgid"`
+	Sid            int               `json:"sid"`
+	TtyNr          int               `json:"ttyNr"`
+	Terminal       string            `json:"terminal"`
+	Children       []ChildrenProcess `json:"children"`
+	SumCpuPercent  float64           `json:"sumCpuPercent"`
+	SumRss         string            `json:"sumRss"`
+	IO             *IOCounters       `json:"io"`
+	IORates        *IORates          `json:"ioRates"`
+	SumIO          *IOCounters       `json:"sumIo"`
+	SumIORates     *IORates          `json:"sumIoRates"`
+	Memory         *MemoryDetail     `json:"memory"`
+	SumMemory      *MemoryDetail     `json:"sumMemory"`
+	SumPss         string            `json:"sumPss"`
 }
 
 type Processes []Process
 
+// FormatStartTime 起動時刻を指定された書式とタイムゾーンの文字列で返す。locがnilならローカルタイム
+func (p *Process) FormatStartTime(layout string, loc *time.Location) string {
+	if p.StartTime.IsZero() {
+		return ""
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	return p.StartTime.In(loc).Format(layout)
+}
+
 // プロセス起動・停止に必要な情報
 type ProcessParam struct {
 	SetEnv     []string `json:"setEnv"`
@@ -66,17 +106,62 @@ type ProcessParam struct {
 	Args       string   `json:"args"`
 	RecordPid  bool     `json:"recordPid"`
 	PidFile    string   `json:"pidFile"`
+	// 起動するユーザー・グループ(名前かID)。空なら起動元と同じ
+	User                string   `json:"user"`
+	Group               string   `json:"group"`
+	SupplementaryGroups []string `json:"supplementaryGroups"`
+	// リソース制限。キーはnofile, core, as等のリソース名(Linuxのみ)
+	// 起動元と違う値があればexec直後にptraceで止めて適用するので、ptraceが禁止された環境では起動できない
+	// また、トレース中のexecでは昇格しないので、root以外はsetuidやファイルケーパビリティのあるプログラムを起動できない
+	Limits map[string]Limit `json:"limits"`
+	// cgroup v2でのリソース制御。nilならcgroupを作らない(Linuxのみ)
+	Cgroup *CgroupParam `json:"cgroup"`
+	// oom_score_adj(-1000から1000)。nilなら起動元から引き継ぐ(Linuxのみ)
+	// 起動元と違う値ならLimitsと同じくptraceでexec直後に止めて設定する
+	OOMScoreAdj *int `json:"oomScoreAdj"`
+	// nice値(-20から19)。nilなら起動元から引き継ぐ(Linuxのみ)
+	Nice *int `json:"nice"`
+	// I/Oスケジューリングクラス(realtime, best-effort, idle)と優先度(0から7)。空なら起動元から引き継ぐ(Linuxのみ)
+	IOClass    string `json:"ioClass"`
+	IOPriority int    `json:"ioPriority"`
+	// 動作させるCPU番号。空なら起動元から引き継ぐ(Linuxのみ)
+	CPUAffinity []int `json:"cpuAffinity"`
+	// 起動元が死んだ時にサービスに送るシグナル("SIGTERM"、"KILL"、"9"等)。空なら送らない(Linuxのみ)
+	Pdeathsig string `json:"pdeathsig"`
+	// サービスに付けるラベル。GOPROC_LABEL_<キー>の環境変数で渡し、GetProcessやAggregateで使う
+	Labels map[string]string `json:"labels"`
 }
 
-const timeformat = "2006/01/02 15:04:05"
+// 以前のCreateTimeの書式
+const LegacyTimeLayout = "2006/01/02 15:04:05"
+
+// CreateTimeLayout 空でなければCreateTimeにStartTimeをこの書式で入れる
+var CreateTimeLayout = ""
+
+// CreateTimeLocation CreateTimeのタイムゾーン
+var CreateTimeLocation = time.Local
 
 var ErrInterrupt = errors.New("interrupt signal accepted.")
+var ErrNoPrivilege = errors.New("no privilege to change user or group.")
+var ErrNotSupported = errors.New("not supported on this platform.")
+var ErrOOMKilled = errors.New("killed by oom killer.")
+
+// notSupported gopsutilが未対応のプラットフォームで返すエラーをErrNotSupportedにする
+// gopsutilのErrNotImplementedErrorはinternalパッケージにあって比較できないのでメッセージで判定する
+func notSupported(err error) error {
+	if err != nil && err.Error() == "not implemented yet" {
+		return ErrNotSupported
+	}
+	return err
+}
 
 // GetProcesses 指定されたPIDのプロセス情報をまとめて返す
 func GetProcesses(pids []int) (Processes, error) {
 	ret := []Process{}
+	// 子孫の集計に使うプロセスツリーはまとめて1回だけ作る
+	tree, _ := processTree()
 	for _, pid := range pids {
-		p, err := GetProcess(pid)
+		p, err := getProcess(pid, nil, tree)
 		if err != nil {
 			// errorならスキップする(全部エラーなら0個返す)
 			continue
@@ -89,6 +174,12 @@ func GetProcesses(pids []int) (Processes, error) {
 
 // GetProcess 指定されたPIDのプロセス情報を返す
 func GetProcess(pid int) (*Process, error) {
+	return getProcess(pid, nil, nil)
+}
+
+// getProcess 指定されたPIDのプロセス情報を返す。Samplerがあれば前回からの差分でCPU使用率とI/Oの速度を計算する
+// treeは子孫の集計に使うプロセスツリーで、nilなら全プロセスを走査して作る
+func getProcess(pid int, s *Sampler, tree map[int][]int) (*Process, error) {
 	ret := &Process{}
 
 	// 渡されたpidがマイナス、0、1の時はエラーで返す(そうじゃないとPanicになる)
@@ -116,7 +207,7 @@ func GetProcess(pid int) (*Process, error) {
 	}
 
 	//cpupercent, err := p.CPUPercent()
-	cpupercent, err := getCPUPercent(p)
+	cpupercent, err := s.cpuPercent(p)
 	if err != nil {
 		log.Printf("error: %v, get process.CPUPercent: %v", ret.Name, err)
 		ret.CpuPercent = 0
@@ -150,6 +241,12 @@ func GetProcess(pid int) (*Process, error) {
 		ret.Vms = bytesize.New(float64(memory.VMS)).String()
 		ret.Rss = bytesize.New(float64(memory.RSS)).String()
 		ret.Swap = bytesize.New(float64(memory.Swap)).String()
+		ret.MemPercent = memPercent(memory.RSS)
+	}
+
+	ret.Memory, err = getMemoryDetail(pid)
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		log.Printf("error: %v, get memory detail: %v", ret.Name, err)
 	}
 
 	ret.Cmdline, err = p.Cmdline()
@@ -176,22 +273,79 @@ func GetProcess(pid int) (*Process, error) {
 		for _, v := range envs {
 			ret.Env = append(ret.Env, v)
 		}
+		ret.EnvMap = EnvToMap(envs)
+		ret.Labels = labelsFromEnv(ret.EnvMap)
 	}
 
-	createtime, err := p.CreateTime()
+	ret.IO, err = getIOCounters(pid)
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		log.Printf("error: %v, get io counters: %v", ret.Name, err)
+	}
+	ret.IORates = s.ioRates(p, "io", ret.IO)
+
+	ret.Limits, err = getLimits(pid)
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		log.Printf("error: %v, get limits: %v", ret.Name, err)
+	}
+
+	numthreads, err := p.NumThreads()
 	if err != nil {
-		log.Printf("error: %v, get process.CreateTime: %v", ret.Name, err)
+		log.Printf("error: %v, get process.NumThreads: %v", ret.Name, err)
+	}
+	ret.NumThreads = int(numthreads)
+
+	// WindowsのNumFDs()はgopsutilが未対応
+	numfds, err := p.NumFDs()
+	if err = notSupported(err); err != nil && !errors.Is(err, ErrNotSupported) {
+		log.Printf("error: %v, get process.NumFDs: %v", ret.Name, err)
+	}
+	ret.NumFDs = int(numfds)
+	if l, ok := ret.Limits["nofile"]; ok {
+		ret.NofileLimit = l.Soft
 	}
-	ret.CreateTime = time.Unix(createtime/1000, 0).Format(timeformat)
 
-	// Winだとnot implemented yetとなるプロセスがいる（規則性が不明）。MacはOKなのでこの値は取らない
-	/*
-		statuses, err := p.Status()
+	if CollectListeningPorts {
+		conns, err := getConnections(pid)
 		if err != nil {
-			log.Printf("error: %v, get process.Status: %v", ret.Name, err)
+			log.Printf("error: %v, get connections: %v", ret.Name, err)
+		} else {
+			ret.ListeningPorts = listeningPorts(conns)
 		}
-		ret.Status = strings.Join(statuses, ", ")
-	*/
+	}
+
+	ret.Cgroup, err = getCgroupInfo(pid)
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		log.Printf("error: %v, get cgroup: %v", ret.Name, err)
+	}
+
+	ret.OomScore, ret.OomScoreAdj, err = getOOMScore(pid)
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		log.Printf("error: %v, get oom_score: %v", ret.Name, err)
+	}
+
+	ret.Nice, ret.SchedPolicy, ret.CpusAllowed, err = getSchedule(pid)
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		log.Printf("error: %v, get schedule: %v", ret.Name, err)
+	}
+
+	createtime, err := p.CreateTime()
+	if err != nil {
+		log.Printf("error: %v, get process.CreateTime: %v", ret.Name, err)
+	}
+	// CreateTime()はミリ秒なので切り捨てずに持つ
+	if createtime > 0 {
+		ret.StartTime = time.Unix(createtime/1000, (createtime%1000)*int64(time.Millisecond))
+		ret.Uptime = time.Since(ret.StartTime)
+		if CreateTimeLayout != "" {
+			ret.CreateTime = ret.FormatStartTime(CreateTimeLayout, CreateTimeLocation)
+		}
+	}
+
+	// Winだとnot implemented yetとなるプロセスがいる（規則性が不明）のでunsupportedになる
+	ret.Status, ret.SchedStats, err = getStatus(p)
+	if err != nil {
+		log.Printf("error: %v, get process.Status: %v", ret.Name, err)
+	}
 
 	ret.Pid = int(p.Pid)
 
@@ -201,8 +355,21 @@ func GetProcess(pid int) (*Process, error) {
 	}
 	ret.Ppid = int(ppid)
 
+	ret.Owner, err = getOwner(p)
+	if err != nil {
+		log.Printf("error: %v, get owner: %v", ret.Name, err)
+	}
+	ret.Pgid, ret.Sid, ret.TtyNr, err = getSession(pid)
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		log.Printf("error: %v, get session: %v", ret.Name, err)
+	}
+	// 制御端末が無ければ空(Mac、Winはnot implemented yet)
+	if ret.TtyNr != 0 {
+		ret.Terminal, _ = p.Terminal()
+	}
+
 	// 子プロセス情報取得
-	cp, sumcpu, sumrss, err := GetChildProcess(pid)
+	cp, sumcpu, sumrss, err := getChildProcess(pid, s.cpuMode())
 	if err != nil {
 		log.Printf("error: %v(%d), get child processes: %v", ret.Name, pid, err)
 		return ret, nil
@@ -215,11 +382,30 @@ func GetProcess(pid int) (*Process, error) {
 	ret.SumCpuPercent = math.Round(sumcpu*10) / 10
 	ret.SumRss = bytesize.New(float64(sumrss)).String()
 
+	// I/Oは孫以降のプロセスも含めて合計する
+	if tree == nil {
+		tree, _ = processTree()
+	}
+	descs := descendants(pid, tree)
+	ret.SumIO = getTreeIOCounters(descs, ret.IO)
+	ret.SumIORates = s.ioRates(p, "sumio", ret.SumIO)
+
+	// RSSの合計は共有ページを重複して数えるので、PSSも孫以降のプロセスを含めて合計する
+	if ret.Memory != nil {
+		ret.SumMemory = getTreeMemoryDetail(descs, ret.Memory)
+		ret.SumPss = bytesize.New(float64(ret.SumMemory.Pss)).String()
+	}
+
 	return ret, nil
 }
 
 // GetChildProcess 指定されたPIDの子プロセス情報を返す
 func GetChildProcess(pid int) ([]ChildrenProcess, float64, uint64, error) {
+	return getChildProcess(pid, defaultCPUPercentMode)
+}
+
+// getChildProcess 子プロセス情報を返す。CPU使用率はmodeに合わせる
+func getChildProcess(pid int, mode CPUMode) ([]ChildrenProcess, float64, uint64, error) {
 	p, err := process.NewProcess(int32(pid))
 	if err != nil {
 		return nil, 0, 0, err
@@ -259,8 +445,9 @@ func GetChildProcess(pid int) ([]ChildrenProcess, float64, uint64, error) {
 			log.Printf("error: %v, get process.Children.CPUPercent: %v", cname, err)
 			ccpu = 0
 		} else {
-			ccpu = math.Round(ccpupercent*10) / 10
-			sumcpu = sumcpu + ccpupercent
+			// 自プロセスと合計するのでCPU使用率の表し方を合わせる
+			ccpu = normalizeCPUPercent(mode, ccpupercent)
+			sumcpu = sumcpu + ccpu
 		}
 		cmemory, err := c.MemoryInfo()
 		var cvms, crss, cswap string
@@ -338,19 +525,74 @@ func StartService(done chan<- error, param ProcessParam) {
 	} else {
 		cmd = exec.Command(param.Command)
 	}
-	stdout, _ := cmd.StdoutPipe()
-	stderr, _ := cmd.StderrPipe()
-	stdoutStderr := io.MultiReader(stdout, stderr)
-
 	cmd.Dir = param.WorkingDir
 	if len(env) > 0 {
 		cmd.Env = env
 	}
+	if len(param.Labels) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		for k, v := range param.Labels {
+			cmd.Env = setEnvValue(cmd.Env, LabelEnvPrefix+k, v)
+		}
+	}
 
 	setService(cmd)
-	if err := cmd.Start(); err != nil {
+	// Pipeを作る前に失敗させないとファイルディスクリプタが漏れる
+	if err := setCredential(cmd, param); err != nil {
+		done <- err
+		return
+	}
+	if err := setPdeathsig(cmd, param); err != nil {
+		done <- err
+		return
+	}
+	cg, err := createCgroup(param)
+	if err != nil {
 		done <- err
+		return
+	}
+	if err := cg.setCmd(cmd); err != nil {
+		cg.destroy()
+		done <- err
+		return
+	}
+
+	stdout, _ := cmd.StdoutPipe()
+	stderr, _ := cmd.StderrPipe()
+	stdoutStderr := io.MultiReader(stdout, stderr)
+
+	// 制限が掛からないまま動き出さないように、プログラムが動き出す前に適用して、適用できなければ起動しない
+	prepares := []func(pid int) error{}
+	if attach := cg.attacher(); attach != nil {
+		prepares = append(prepares, attach)
+	}
+	// 起動元と同じ値は引き継がれるので、違う値がある時だけexec前に止める(ptrace)
+	if limits := pendingLimits(param.Limits); len(limits) > 0 {
+		prepares = append(prepares, func(pid int) error { return applyLimits(pid, limits) })
+	}
+	if oomScoreAdjChanged(param.OOMScoreAdj) {
+		prepares = append(prepares, func(pid int) error { return applyOOMScoreAdj(pid, param.OOMScoreAdj) })
+	}
+
+	// nice、I/O優先度、CPUアフィニティは起動するスレッドに設定して子プロセスに引き継がせる
+	var setup func() error
+	if param.Nice != nil || param.IOClass != "" || len(param.CPUAffinity) > 0 {
+		setup = func() error { return setThreadSchedule(param) }
+	}
+
+	oomBefore := oomKillCount(cg)
+	release, err := startCmd(cmd, setup, prepares...)
+	if err != nil {
+		cg.destroy()
+		done <- err
+		return
 	} else {
+		// サブリーパーがcmd.Wait()より先に回収しないようにする
+		TrackChild(cmd.Process.Pid)
+		defer UntrackChild(cmd.Process.Pid)
+
 		if param.RecordPid {
 			if err := CreatePidFile(cmd.Process.Pid, param.PidFile); err != nil {
 				cmd.Wait()
@@ -364,7 +606,14 @@ func StartService(done chan<- error, param ProcessParam) {
 		fmt.Println(scanner.Text())
 	}
 
-	if err := cmd.Wait(); err != nil {
+	// OOM Killerで殺されたかの判定にcgroupのmemory.eventsを使うので片付ける前に判定する
+	err = classifyExit(cmd.Wait(), oomBefore, cg)
+	release()
+	// 次の起動と競合しないように、終了を知らせる前にcgroupに残ったプロセスを終了させて片付ける
+	if cerr := cg.destroy(); cerr != nil {
+		log.Printf("error: %v, destroy cgroup: %v", param.Command, cerr)
+	}
+	if err != nil {
 		done <- err
 	}
 
@@ -396,6 +645,8 @@ func StopService(param ProcessParam) error {
 	if err != nil {
 		return err
 	}
+	TrackChild(cmd.Process.Pid)
+	defer UntrackChild(cmd.Process.Pid)
 	scanner := bufio.NewScanner(stdoutStderr)
 	for scanner.Scan() {
 		fmt.Println(scanner.Text())
@@ -409,6 +660,7 @@ func StopService(param ProcessParam) error {
 }
 
 // StopServiceByPid PIDでプロセスを識別してシグナルを送信して終了する
+// シグナルを送るだけなので、終了を待つ場合はWaitForExitを使う
 func StopServiceByPid(pid int) error {
 	p, err := os.FindProcess(pid)
 	if err != nil {
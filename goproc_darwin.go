@@ -1,7 +1,7 @@
 package goproc
 
 import (
-	"math"
+	"fmt"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -14,6 +14,9 @@ import (
 // overwritten with os.Interrupt on windows environment (see goproc_windows.go)
 var stopSignal = syscall.SIGTERM
 
+// CPU使用率の表し方の既定(アクティビティモニタと同じく1コアで100%)
+const defaultCPUPercentMode = CPUModePerCore
+
 // setService Group PidとSession idを親プロセスから分離する
 func setService(cmd *exec.Cmd) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
@@ -27,8 +30,7 @@ func getCPUPercent(p *process.Process) (float64, error) {
 	if err != nil {
 		return 0, err
 	} else {
-		// 小数点一桁で返す。Macのアクティビティモニタはコア毎のCPU使用率が出るのでこのまま返せばよい
-		return math.Round(cpupercent*10) / 10, nil
+		return normalizeCPUPercent(defaultCPUPercentMode, cpupercent), nil
 	}
 }
 
@@ -119,3 +121,20 @@ func getEnvironFromPsCommand(str string) []string {
 	//log.Printf("%#v", envs)
 	return envs
 }
+
+// setPdeathsig 親プロセス終了時のシグナルはLinuxのみ対応
+func setPdeathsig(cmd *exec.Cmd, param ProcessParam) error {
+	if param.Pdeathsig == "" {
+		return nil
+	}
+	return fmt.Errorf("%w: pdeathsig", ErrNotSupported)
+}
+
+// getStatus MacはgopsutilのStatus()で状態が取れる。スケジューリングの統計はnot implemented yetなので取らない
+func getStatus(p *process.Process) (string, *SchedStats, error) {
+	statuses, err := p.Status()
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.Join(statuses, ", "), nil, nil
+}
@@ -0,0 +1,94 @@
+package goproc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"golang.org/x/sys/unix"
+)
+
+var stopSignal = syscall.SIGTERM
+
+// CPU使用率の表し方の既定(topと同じく1コアで100%)
+const defaultCPUPercentMode = CPUModePerCore
+
+// ホストのバイトオーダー(プロセスコネクタのメッセージや/proc/netのアドレスで使う)
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// setService Session idを親プロセスから分離する(Setsidで新しいプロセスグループも作られる)
+func setService(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// parseSignal "SIGTERM"、"TERM"、"15"のどれかの形式でシグナルを返す
+func parseSignal(name string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(name); err == nil {
+		return syscall.Signal(n), nil
+	}
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig := unix.SignalNum(name)
+	if sig == 0 {
+		return 0, fmt.Errorf("unknown signal: %s", name)
+	}
+	return sig, nil
+}
+
+// setPdeathsig 起動元(goprocを使っているプロセス)が死んだ時に子プロセスに送るシグナルを設定する
+// Linuxでは親のスレッドが終了した時にも送られるが、GoはLockOSThreadしたまま終わらない限りスレッドを終了させない
+func setPdeathsig(cmd *exec.Cmd, param ProcessParam) error {
+	if param.Pdeathsig == "" {
+		return nil
+	}
+	sig, err := parseSignal(param.Pdeathsig)
+	if err != nil {
+		return err
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Pdeathsig = sig
+	return nil
+}
+
+func getCPUPercent(p *process.Process) (float64, error) {
+	// CPUPercent()はtopと違う。同じような値はPercent()で取れる(https://github.com/shirou/gopsutil/issues/1006)
+	// topの標準は3秒更新だが、ブロッキングしてしまうので1秒にする
+	cpupercent, err := p.Percent(1 * time.Second)
+	if err != nil {
+		return 0, err
+	} else {
+		return normalizeCPUPercent(defaultCPUPercentMode, cpupercent), nil
+	}
+}
+
+// GetEnviron 環境変数取得。Linuxは/proc/<pid>/environを読むだけなので単なるWrapper
+func GetEnviron(p *process.Process) ([]string, error) {
+	envs, err := p.Environ()
+	if err != nil {
+		return nil, err
+	}
+	// NUL区切りの末尾で空要素ができるので除去する
+	ret := []string{}
+	for _, e := range envs {
+		if e != "" {
+			ret = append(ret, e)
+		}
+	}
+	return ret, nil
+}
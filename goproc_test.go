@@ -1,6 +1,7 @@
 package goproc_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -91,6 +92,48 @@ func TestGetProcesses(t *testing.T) {
 	}
 }
 
+func TestStartTime(t *testing.T) {
+	p, err := goproc.GetProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("GetProcess = %s, Failed", err)
+	}
+	if p.StartTime.IsZero() || p.StartTime.After(time.Now()) {
+		t.Errorf("StartTime = %v, Failed", p.StartTime)
+	}
+	if p.Uptime <= 0 {
+		t.Errorf("Uptime = %v, Failed", p.Uptime)
+	}
+	if p.CreateTime != "" {
+		t.Errorf("CreateTime = %v, expect empty, Failed", p.CreateTime)
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		StartTime string `json:"startTime"`
+	}
+	json.Unmarshal(b, &v)
+	if _, err := time.Parse(time.RFC3339, v.StartTime); err != nil {
+		t.Errorf("startTime = %v, Failed", v.StartTime)
+	}
+
+	goproc.CreateTimeLayout = goproc.LegacyTimeLayout
+	goproc.CreateTimeLocation = time.UTC
+	defer func() {
+		goproc.CreateTimeLayout = ""
+		goproc.CreateTimeLocation = time.Local
+	}()
+	p, err = goproc.GetProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("GetProcess = %s, Failed", err)
+	}
+	if expect := p.StartTime.UTC().Format(goproc.LegacyTimeLayout); p.CreateTime != expect {
+		t.Errorf("CreateTime = %v, expect = %v, Failed", p.CreateTime, expect)
+	}
+}
+
 func TestStopService(t *testing.T) {
 	usr, _ := user.Current()
 	p := []goproc.ProcessParam{
@@ -158,7 +201,7 @@ func TestStartService(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.msg, func(t *testing.T) {
 			// Ctrl+Cを受け取る
-			quit := make(chan os.Signal)
+			quit := make(chan os.Signal, 1)
 			signal.Notify(quit, os.Interrupt)
 			done := make(chan error, 1)
 			go goproc.StartService(done, c.param)
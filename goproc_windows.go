@@ -1,10 +1,9 @@
 package goproc
 
 import (
-	"math"
+	"fmt"
 	"os"
 	"os/exec"
-	"runtime"
 	"time"
 
 	"github.com/shirou/gopsutil/v3/process"
@@ -12,6 +11,9 @@ import (
 
 var stopSignal = os.Interrupt
 
+// CPU使用率の表し方の既定(タスクマネージャーと同じく全コアで100%)
+const defaultCPUPercentMode = CPUModeMachine
+
 // setService Group PidとSession idを親プロセスから分離する Windowsのやり方が分かるまで空にしておく
 func setService(cmd *exec.Cmd) {
 	return
@@ -24,9 +26,7 @@ func getCPUPercent(p *process.Process) (float64, error) {
 	if err != nil {
 		return 0, err
 	} else {
-		// Winのタスクマネージャーは全コア合計のCPU使用率が出るのでコア数で割る
-		// Winのタスクマネージャーは小数点以下切り捨てだが、小数点以下一位を四捨五入で出す
-		return math.Round(cpupercent/float64(runtime.NumCPU())*10) / 10, nil
+		return normalizeCPUPercent(defaultCPUPercentMode, cpupercent), nil
 	}
 }
 
@@ -38,3 +38,16 @@ func GetEnviron(p *process.Process) ([]string, error) {
 	}
 	return envs, nil
 }
+
+// setPdeathsig 親プロセス終了時のシグナルはLinuxのみ対応
+func setPdeathsig(cmd *exec.Cmd, param ProcessParam) error {
+	if param.Pdeathsig == "" {
+		return nil
+	}
+	return fmt.Errorf("%w: pdeathsig", ErrNotSupported)
+}
+
+// getStatus Winだとnot implemented yetとなるプロセスがいる（規則性が不明）のでunsupportedにする
+func getStatus(p *process.Process) (string, *SchedStats, error) {
+	return StatusUnsupported, nil, nil
+}
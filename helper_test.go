@@ -0,0 +1,28 @@
+package goproc
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// StartSleep goproc_testパッケージのテストからstartSleepを使う
+var StartSleep = startSleep
+
+// startSleep テスト対象にするsleepを起動する。envを指定すると環境変数に追加する
+// テストが終わったら終了させて回収する
+func startSleep(t *testing.T, seconds string, env ...string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command("sleep", seconds)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("sleep can't start: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd
+}
@@ -0,0 +1,135 @@
+package goproc
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// CPU使用率の表し方
+type CPUMode string
+
+const (
+	// 1コアを使い切ると100%(Linuxのtop、Macのアクティビティモニタと同じ)
+	CPUModePerCore CPUMode = "per-core"
+	// 全コアを使い切ると100%(Winのタスクマネージャーと同じ)
+	CPUModeMachine CPUMode = "machine"
+)
+
+// 取得時点のマシン全体の情報。使用率の分母になる
+type HostSnapshot struct {
+	Time         time.Time     `json:"time"`
+	NumCPU       int           `json:"numCpu"`
+	CPUMode      CPUMode       `json:"cpuMode"`
+	MemTotal     uint64        `json:"memTotal"`
+	MemAvailable uint64        `json:"memAvailable"`
+	Load1        float64       `json:"load1"`
+	Load5        float64       `json:"load5"`
+	Load15       float64       `json:"load15"`
+	Uptime       time.Duration `json:"uptime"`
+	BootTime     time.Time     `json:"bootTime"`
+}
+
+// マシン全体の情報を付けたプロセス一覧
+type Listing struct {
+	Host      *HostSnapshot `json:"host"`
+	Processes Processes     `json:"processes"`
+}
+
+// GetHostSnapshot マシン全体の情報を返す。ロードアベレージが取れない環境では0
+// CPUModeはSamplerを使わない関数のCPU使用率の表し方(WinはCPUModeMachine、それ以外はCPUModePerCore)
+func GetHostSnapshot() (*HostSnapshot, error) {
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		return nil, err
+	}
+	ret := &HostSnapshot{
+		Time:         time.Now(),
+		NumCPU:       numCPU(),
+		CPUMode:      defaultCPUPercentMode,
+		MemTotal:     vm.Total,
+		MemAvailable: vm.Available,
+	}
+	if avg, err := load.Avg(); err == nil {
+		ret.Load1, ret.Load5, ret.Load15 = avg.Load1, avg.Load5, avg.Load15
+	}
+	uptime, err := host.Uptime()
+	if err != nil {
+		return nil, err
+	}
+	ret.Uptime = time.Duration(uptime) * time.Second
+	boottime, err := host.BootTime()
+	if err != nil {
+		return nil, err
+	}
+	ret.BootTime = time.Unix(int64(boottime), 0)
+	return ret, nil
+}
+
+// NewListing プロセス一覧にマシン全体の情報を付ける
+func NewListing(ps Processes) (*Listing, error) {
+	var s *Sampler
+	return s.newListing(ps)
+}
+
+// newListing プロセス一覧にマシン全体の情報を付ける。CPUModeはSamplerの設定にする
+func (s *Sampler) newListing(ps Processes) (*Listing, error) {
+	h, err := GetHostSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	h.CPUMode = s.cpuMode()
+	return &Listing{Host: h, Processes: ps}, nil
+}
+
+// 論理CPU数は変わらないので一度だけ取る
+var logicalCPUs struct {
+	once sync.Once
+	n    int
+}
+
+// numCPU ホストの論理CPU数を返す
+// runtime.NumCPU()は自プロセスのCPUアフィニティ(tasksetやcpuset)の数になるので、取れない時だけ使う
+func numCPU() int {
+	logicalCPUs.once.Do(func() {
+		if n, err := cpu.Counts(true); err == nil && n > 0 {
+			logicalCPUs.n = n
+		} else {
+			logicalCPUs.n = runtime.NumCPU()
+		}
+	})
+	return logicalCPUs.n
+}
+
+// normalizeCPUPercent modeに合わせて小数点一桁で返す
+func normalizeCPUPercent(mode CPUMode, cpupercent float64) float64 {
+	if mode == CPUModeMachine {
+		cpupercent = cpupercent / float64(numCPU())
+	}
+	return math.Round(cpupercent*10) / 10
+}
+
+// 搭載メモリ量は変わらないので一度だけ取る
+var memTotal struct {
+	once  sync.Once
+	total uint64
+}
+
+// memPercent 搭載メモリに対するRSSの割合を小数点一桁で返す
+func memPercent(rss uint64) float64 {
+	memTotal.once.Do(func() {
+		if vm, err := mem.VirtualMemory(); err == nil {
+			memTotal.total = vm.Total
+		}
+	})
+	if memTotal.total == 0 {
+		return 0
+	}
+	return math.Round(float64(rss)/float64(memTotal.total)*1000) / 10
+}
@@ -0,0 +1,49 @@
+package goproc
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestHostSnapshot(t *testing.T) {
+	h, err := GetHostSnapshot()
+	if err != nil {
+		t.Fatalf("GetHostSnapshot = %s, Failed", err)
+	}
+	if h.NumCPU < runtime.NumCPU() || h.CPUMode != defaultCPUPercentMode || h.MemTotal == 0 || h.MemAvailable > h.MemTotal || h.Uptime <= 0 {
+		t.Errorf("GetHostSnapshot = %#v, Failed", h)
+	}
+
+	l, err := NewListing(Processes{{Pid: 1}})
+	if err != nil || l.Host == nil || len(l.Processes) != 1 {
+		t.Errorf("NewListing = %v, %#v, Failed", err, l)
+	}
+
+	p, err := GetProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("GetProcess = %s, Failed", err)
+	}
+	if p.MemPercent <= 0 || p.MemPercent > 100 {
+		t.Errorf("MemPercent = %v, Failed", p.MemPercent)
+	}
+}
+
+func TestCPUPercentMode(t *testing.T) {
+	if v := normalizeCPUPercent(CPUModePerCore, 150.04); v != 150 {
+		t.Errorf("per-core = %v, expect = 150, Failed", v)
+	}
+	if v := normalizeCPUPercent(CPUModeMachine, float64(numCPU())*50); v != 50 {
+		t.Errorf("machine = %v, expect = 50, Failed", v)
+	}
+
+	// Samplerごとに表し方を変えられる
+	s := &Sampler{CPUMode: CPUModeMachine}
+	if s.cpuMode() != CPUModeMachine || NewSampler().cpuMode() != defaultCPUPercentMode {
+		t.Errorf("cpuMode = %s, %s, Failed", s.cpuMode(), NewSampler().cpuMode())
+	}
+	l, err := s.newListing(nil)
+	if err != nil || l.Host.CPUMode != CPUModeMachine {
+		t.Errorf("newListing = %v, %#v, Failed", err, l)
+	}
+}
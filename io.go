@@ -0,0 +1,64 @@
+package goproc
+
+// プロセスのディスクI/Oカウンター(起動からの累計)
+type IOCounters struct {
+	ReadBytes           uint64 `json:"readBytes"`
+	WriteBytes          uint64 `json:"writeBytes"`
+	ReadCount           uint64 `json:"readCount"`
+	WriteCount          uint64 `json:"writeCount"`
+	CancelledWriteBytes uint64 `json:"cancelledWriteBytes"`
+}
+
+// 1秒あたりのディスクI/O(Samplerで前回取得した値との差分から計算する)
+type IORates struct {
+	ReadBytes           float64 `json:"readBytes"`
+	WriteBytes          float64 `json:"writeBytes"`
+	ReadCount           float64 `json:"readCount"`
+	WriteCount          float64 `json:"writeCount"`
+	CancelledWriteBytes float64 `json:"cancelledWriteBytes"`
+}
+
+// add 子プロセスのカウンターを足し込む
+func (c *IOCounters) add(o *IOCounters) {
+	c.ReadBytes += o.ReadBytes
+	c.WriteBytes += o.WriteBytes
+	c.ReadCount += o.ReadCount
+	c.WriteCount += o.WriteCount
+	c.CancelledWriteBytes += o.CancelledWriteBytes
+}
+
+// ioRates prevからcurまでのseconds秒間の1秒あたりの値を返す
+// 子プロセスが終了して合計が減った場合は0にする
+func ioRates(prev, cur IOCounters, seconds float64) *IORates {
+	if seconds <= 0 {
+		return &IORates{}
+	}
+	rate := func(p, c uint64) float64 {
+		if c < p {
+			return 0
+		}
+		return float64(c-p) / seconds
+	}
+	return &IORates{
+		ReadBytes:           rate(prev.ReadBytes, cur.ReadBytes),
+		WriteBytes:          rate(prev.WriteBytes, cur.WriteBytes),
+		ReadCount:           rate(prev.ReadCount, cur.ReadCount),
+		WriteCount:          rate(prev.WriteCount, cur.WriteCount),
+		CancelledWriteBytes: rate(prev.CancelledWriteBytes, cur.CancelledWriteBytes),
+	}
+}
+
+// getTreeIOCounters 自プロセスと全子孫プロセス(descs)のI/Oカウンターの合計を返す
+// 権限が無くて読めない子孫は合計に含めない
+func getTreeIOCounters(descs []int, self *IOCounters) *IOCounters {
+	ret := &IOCounters{}
+	if self != nil {
+		ret.add(self)
+	}
+	for _, d := range descs {
+		if c, err := getIOCounters(d); err == nil {
+			ret.add(c)
+		}
+	}
+	return ret
+}
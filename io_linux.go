@@ -0,0 +1,45 @@
+package goproc
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// getIOCounters 指定されたPIDのI/Oカウンターを/proc/<pid>/ioから取得する
+func getIOCounters(pid int) (*IOCounters, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/io", pid))
+	if err != nil {
+		return nil, err
+	}
+	return parseProcIO(string(b))
+}
+
+// parseProcIO /proc/<pid>/ioをパースする
+func parseProcIO(content string) (*IOCounters, error) {
+	ret := &IOCounters{}
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid io counter %s: %w", line, err)
+		}
+		switch key {
+		case "read_bytes":
+			ret.ReadBytes = n
+		case "write_bytes":
+			ret.WriteBytes = n
+		case "syscr":
+			ret.ReadCount = n
+		case "syscw":
+			ret.WriteCount = n
+		case "cancelled_write_bytes":
+			ret.CancelledWriteBytes = n
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,67 @@
+package goproc
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseProcIO(t *testing.T) {
+	io, err := parseProcIO("rchar: 5171\nwchar: 1284\nsyscr: 12\nsyscw: 4\nread_bytes: 4096\nwrite_bytes: 8192\ncancelled_write_bytes: 1024\n")
+	if err != nil {
+		t.Fatalf("parseProcIO = %s, Failed", err)
+	}
+	except := IOCounters{ReadBytes: 4096, WriteBytes: 8192, ReadCount: 12, WriteCount: 4, CancelledWriteBytes: 1024}
+	if *io != except {
+		t.Errorf("parseProcIO = %#v, expect = %#v, Failed", *io, except)
+	}
+
+	if _, err := parseProcIO("read_bytes: x\n"); err == nil {
+		t.Errorf("parseProcIO nothing err, Failed")
+	}
+}
+
+func TestIORates(t *testing.T) {
+	prev := IOCounters{ReadBytes: 1000, WriteBytes: 5000}
+	cur := IOCounters{ReadBytes: 3000, WriteBytes: 4000}
+	r := ioRates(prev, cur, 2)
+	if r.ReadBytes != 1000 || r.WriteBytes != 0 {
+		t.Errorf("ioRates = %#v, Failed", r)
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	tree := map[int][]int{1: {10, 20}, 10: {11, 12}, 12: {13}, 20: {21}}
+	d := descendants(10, tree)
+	sort.Ints(d)
+	if !reflect.DeepEqual(d, []int{11, 12, 13}) {
+		t.Errorf("descendants = %v, Failed", d)
+	}
+
+	io, err := getIOCounters(os.Getpid())
+	if err != nil {
+		t.Fatalf("getIOCounters = %s, Failed", err)
+	}
+	tree, err = processTree()
+	if err != nil {
+		t.Fatalf("processTree = %s, Failed", err)
+	}
+	if sum := getTreeIOCounters(descendants(os.Getpid(), tree), io); sum.ReadCount < io.ReadCount {
+		t.Errorf("getTreeIOCounters = %#v, Failed", sum)
+	}
+}
+
+func TestNotSupported(t *testing.T) {
+	if err := notSupported(errors.New("not implemented yet")); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("notSupported = %v, expect ErrNotSupported, Failed", err)
+	}
+	if err := notSupported(nil); err != nil {
+		t.Errorf("notSupported = %v, expect nil, Failed", err)
+	}
+	other := errors.New("permission denied")
+	if err := notSupported(other); err != other {
+		t.Errorf("notSupported = %v, expect %v, Failed", err, other)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// getIOCounters 指定されたPIDのI/Oカウンターを返す。cancelled_write_bytesはLinuxのみなので0
+// gopsutilが対応していないプラットフォーム(macOS)ではErrNotSupportedを返す
+func getIOCounters(pid int) (*IOCounters, error) {
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return nil, err
+	}
+	io, err := p.IOCounters()
+	if err != nil {
+		return nil, notSupported(err)
+	}
+	return &IOCounters{
+		ReadBytes:  io.ReadBytes,
+		WriteBytes: io.WriteBytes,
+		ReadCount:  io.ReadCount,
+		WriteCount: io.WriteCount,
+	}, nil
+}
@@ -0,0 +1,55 @@
+package goproc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// RlimUnlimited 制限なし(RLIM_INFINITY)
+const RlimUnlimited RlimitValue = math.MaxUint64
+
+// リソース制限の値。JSONでは制限なしを"unlimited"で表す
+type RlimitValue uint64
+
+// リソース制限(rlimit)のソフトリミットとハードリミット
+// Hardが0でSoftが0より大きい場合はハードリミットを変更しない
+type Limit struct {
+	Soft RlimitValue `json:"soft"`
+	Hard RlimitValue `json:"hard"`
+}
+
+// MarshalJSON 制限なしは"unlimited"、それ以外は数値で出力する
+func (v RlimitValue) MarshalJSON() ([]byte, error) {
+	if v == RlimUnlimited {
+		return []byte(`"unlimited"`), nil
+	}
+	return []byte(strconv.FormatUint(uint64(v), 10)), nil
+}
+
+// UnmarshalJSON 数値、数値の文字列、"unlimited"(または"infinity")を受け付ける
+func (v *RlimitValue) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		s = string(b)
+	}
+	parsed, err := ParseRlimitValue(s)
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+// ParseRlimitValue /proc/<pid>/limitsや設定ファイルの値をパースする
+func ParseRlimitValue(s string) (RlimitValue, error) {
+	if s == "unlimited" || s == "infinity" {
+		return RlimUnlimited, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rlimit value: %q", s)
+	}
+	return RlimitValue(n), nil
+}
@@ -0,0 +1,152 @@
+package goproc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// rlimitResources ProcessParam.Limitsに指定できるリソース名(ulimitやsystemdのLimitXXXと同じ名前)
+var rlimitResources = map[string]int{
+	"cpu":        unix.RLIMIT_CPU,
+	"fsize":      unix.RLIMIT_FSIZE,
+	"data":       unix.RLIMIT_DATA,
+	"stack":      unix.RLIMIT_STACK,
+	"core":       unix.RLIMIT_CORE,
+	"rss":        unix.RLIMIT_RSS,
+	"nproc":      unix.RLIMIT_NPROC,
+	"nofile":     unix.RLIMIT_NOFILE,
+	"memlock":    unix.RLIMIT_MEMLOCK,
+	"as":         unix.RLIMIT_AS,
+	"locks":      unix.RLIMIT_LOCKS,
+	"sigpending": unix.RLIMIT_SIGPENDING,
+	"msgqueue":   unix.RLIMIT_MSGQUEUE,
+	"nice":       unix.RLIMIT_NICE,
+	"rtprio":     unix.RLIMIT_RTPRIO,
+	"rttime":     unix.RLIMIT_RTTIME,
+}
+
+// procLimitNames /proc/<pid>/limitsの行名とリソース名の対応
+var procLimitNames = map[string]string{
+	"Max cpu time":          "cpu",
+	"Max file size":         "fsize",
+	"Max data size":         "data",
+	"Max stack size":        "stack",
+	"Max core file size":    "core",
+	"Max resident set":      "rss",
+	"Max processes":         "nproc",
+	"Max open files":        "nofile",
+	"Max locked memory":     "memlock",
+	"Max address space":     "as",
+	"Max file locks":        "locks",
+	"Max pending signals":   "sigpending",
+	"Max msgqueue size":     "msgqueue",
+	"Max nice priority":     "nice",
+	"Max realtime priority": "rtprio",
+	"Max realtime timeout":  "rttime",
+}
+
+// applyLimits プロセスにリソース制限をprlimit(2)で適用する
+// StartServiceではstartCmdでexec直後に止めた子プロセスに適用するので、プログラムは最初から制限された状態で動く
+func applyLimits(pid int, limits map[string]Limit) error {
+	for name, l := range limits {
+		resource, ok := rlimitResources[name]
+		if !ok {
+			return fmt.Errorf("unknown rlimit name: %s", name)
+		}
+
+		var cur unix.Rlimit
+		if err := prlimit(pid, resource, nil, &cur); err != nil {
+			return fmt.Errorf("get rlimit %s: %w", name, err)
+		}
+		newLimit := unix.Rlimit{Cur: uint64(l.Soft), Max: uint64(l.Hard)}
+		if l.Hard == 0 && l.Soft > 0 {
+			newLimit.Max = cur.Max
+		}
+		if err := prlimit(pid, resource, &newLimit, nil); err != nil {
+			return fmt.Errorf("set rlimit %s(soft=%d, hard=%d): %w", name, newLimit.Cur, newLimit.Max, err)
+		}
+	}
+	return nil
+}
+
+// pendingLimits 起動元と違う値のリソース制限だけを返す
+// 起動元と同じ値はforkした子プロセスに引き継がれるので、exec前に止めて(ptrace)適用する必要が無い
+func pendingLimits(limits map[string]Limit) map[string]Limit {
+	ret := map[string]Limit{}
+	for name, l := range limits {
+		resource, ok := rlimitResources[name]
+		var cur unix.Rlimit
+		if !ok || prlimit(0, resource, nil, &cur) != nil {
+			// エラーはapplyLimitsで返す
+			ret[name] = l
+			continue
+		}
+		hard := uint64(l.Hard)
+		if l.Hard == 0 && l.Soft > 0 {
+			hard = cur.Max
+		}
+		if cur.Cur != uint64(l.Soft) || cur.Max != hard {
+			ret[name] = l
+		}
+	}
+	return ret
+}
+
+// prlimit x/sys/unixのバージョンにPrlimitが無いので直接呼ぶ
+func prlimit(pid int, resource int, newLimit *unix.Rlimit, old *unix.Rlimit) error {
+	_, _, errno := unix.RawSyscall6(unix.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(newLimit)), uintptr(unsafe.Pointer(old)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// getLimits 指定されたPIDのリソース制限を/proc/<pid>/limitsから取得する
+func getLimits(pid int) (map[string]Limit, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/limits", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseProcLimits(f)
+}
+
+// parseProcLimits /proc/<pid>/limitsをパースする
+func parseProcLimits(r io.Reader) (map[string]Limit, error) {
+	ret := map[string]Limit{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// 行名にスペースが入っているので、既知の行名で前方一致させる
+		for title, name := range procLimitNames {
+			if !strings.HasPrefix(line, title+" ") {
+				continue
+			}
+			fields := strings.Fields(line[len(title):])
+			if len(fields) < 2 {
+				break
+			}
+			soft, err := ParseRlimitValue(fields[0])
+			if err != nil {
+				return nil, err
+			}
+			hard, err := ParseRlimitValue(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			ret[name] = Limit{Soft: soft, Hard: hard}
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,127 @@
+package goproc
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testProcLimits = `Limit                     Soft Limit           Hard Limit           Units     
+Max cpu time              unlimited            unlimited            seconds   
+Max file size             unlimited            unlimited            bytes     
+Max core file size        0                    unlimited            bytes     
+Max processes             63422                63422                processes 
+Max open files            1024                 524288               files     
+Max address space         unlimited            unlimited            bytes     
+Max realtime timeout      unlimited            unlimited            us        
+`
+
+func TestParseProcLimits(t *testing.T) {
+	limits, err := parseProcLimits(strings.NewReader(testProcLimits))
+	if err != nil {
+		t.Fatalf("parseProcLimits = %s, Failed", err)
+	}
+	if len(limits) != 7 {
+		t.Errorf("parseProcLimits = %d, expect = 7, Failed", len(limits))
+	}
+	if l := limits["nofile"]; l.Soft != 1024 || l.Hard != 524288 {
+		t.Errorf("nofile = %#v, Failed", l)
+	}
+	if l := limits["core"]; l.Soft != 0 || l.Hard != RlimUnlimited {
+		t.Errorf("core = %#v, Failed", l)
+	}
+}
+
+func TestApplyLimits(t *testing.T) {
+	cmd := startSleep(t, "5")
+
+	if err := applyLimits(cmd.Process.Pid, map[string]Limit{"nofile": {Soft: 512}, "core": {Soft: 0, Hard: 0}}); err != nil {
+		t.Fatalf("applyLimits = %s, Failed", err)
+	}
+	limits, err := getLimits(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("getLimits = %s, Failed", err)
+	}
+	if limits["nofile"].Soft != 512 || limits["nofile"].Hard == 0 {
+		t.Errorf("nofile = %#v, Failed", limits["nofile"])
+	}
+	if limits["core"].Soft != 0 || limits["core"].Hard != 0 {
+		t.Errorf("core = %#v, Failed", limits["core"])
+	}
+
+	if err := applyLimits(cmd.Process.Pid, map[string]Limit{"nosuch": {Soft: 1}}); err == nil {
+		t.Errorf("applyLimits nothing err, Failed")
+	}
+}
+
+func TestStartCmdLimits(t *testing.T) {
+	// プログラムの最初からrlimitが掛かっていること
+	cmd := exec.Command("sh", "-c", "ulimit -n")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := map[string]Limit{"nofile": {Soft: 512}}
+	if _, err := startCmd(cmd, nil, func(pid int) error { return applyLimits(pid, limits) }); err != nil {
+		t.Fatalf("startCmd = %s, Failed", err)
+	}
+	b, _ := io.ReadAll(out)
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("sh = %s, Failed", err)
+	}
+	if got := strings.TrimSpace(string(b)); got != "512" {
+		t.Errorf("ulimit -n = %s, expect = 512, Failed", got)
+	}
+
+	// 適用できなければ起動しない
+	cmd = exec.Command("sleep", "5")
+	if _, err := startCmd(cmd, nil, func(pid int) error { return applyLimits(pid, map[string]Limit{"nosuch": {Soft: 1}}) }); err == nil {
+		t.Errorf("startCmd nothing err, Failed")
+	}
+	// 止めたプロセスは終了させて回収してある
+	if cmd.ProcessState == nil {
+		t.Errorf("process is not reaped, Failed")
+	}
+}
+
+func TestPendingLimits(t *testing.T) {
+	self, err := getLimits(os.Getpid())
+	if err != nil {
+		t.Fatalf("getLimits = %s, Failed", err)
+	}
+	// 起動元と同じ値は引き継がれるのでptraceで止める必要が無い
+	nofile := self["nofile"]
+	got := pendingLimits(map[string]Limit{"nofile": nofile, "core": {Soft: self["core"].Soft, Hard: self["core"].Hard}})
+	if len(got) != 0 {
+		t.Errorf("pendingLimits(same) = %#v, Failed", got)
+	}
+	got = pendingLimits(map[string]Limit{"nofile": {Soft: nofile.Soft - 1}, "nosuch": {Soft: 1}})
+	if len(got) != 2 {
+		t.Errorf("pendingLimits(diff) = %#v, Failed", got)
+	}
+}
+
+func TestCheckPtraceExec(t *testing.T) {
+	if err := checkPtraceExec("/bin/sh"); err != nil {
+		t.Skipf("checkPtraceExec = %s", err)
+	}
+	path := filepath.Join(t.TempDir(), "setuid")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0755|os.ModeSetuid); err != nil {
+		t.Fatal(err)
+	}
+	// rootはトレース中でも昇格するので起動できる
+	err := checkPtraceExec(path)
+	if os.Geteuid() == 0 && err != nil {
+		t.Errorf("checkPtraceExec(root) = %s, Failed", err)
+	}
+	if os.Geteuid() != 0 && !errors.Is(err, ErrNotSupported) {
+		t.Errorf("checkPtraceExec(setuid) = %v, Failed", err)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"fmt"
+)
+
+// applyLimits リソース制限の適用はLinuxのみ対応
+func applyLimits(pid int, limits map[string]Limit) error {
+	if len(limits) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: rlimit", ErrNotSupported)
+}
+
+// pendingLimits 起動元の値と比べられないので全部返す
+func pendingLimits(limits map[string]Limit) map[string]Limit {
+	return limits
+}
+
+// getLimits リソース制限の取得はLinuxのみ対応
+func getLimits(pid int) (map[string]Limit, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,45 @@
+package goproc_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gozuk16/goproc"
+)
+
+func TestLimitJSON(t *testing.T) {
+	cases := []struct {
+		in     string
+		except goproc.Limit
+		msg    string
+	}{
+		{`{"soft":1024,"hard":4096}`, goproc.Limit{Soft: 1024, Hard: 4096}, "数値"},
+		{`{"soft":"65536","hard":"unlimited"}`, goproc.Limit{Soft: 65536, Hard: goproc.RlimUnlimited}, "文字列とunlimited"},
+		{`{"soft":0,"hard":0}`, goproc.Limit{}, "0(coreを出さない)"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.msg, func(t *testing.T) {
+			var l goproc.Limit
+			if err := json.Unmarshal([]byte(c.in), &l); err != nil {
+				t.Fatalf("Unmarshal = %s, Failed", err)
+			}
+			if l != c.except {
+				t.Errorf("Unmarshal = %#v, expect %#v, Failed", l, c.except)
+			}
+			b, err := json.Marshal(l)
+			if err != nil {
+				t.Fatalf("Marshal = %s, Failed", err)
+			}
+			var l2 goproc.Limit
+			if err := json.Unmarshal(b, &l2); err != nil || l2 != l {
+				t.Errorf("Marshal = %s, Failed", b)
+			}
+		})
+	}
+
+	var l goproc.Limit
+	if err := json.Unmarshal([]byte(`{"soft":"lots"}`), &l); err == nil {
+		t.Errorf("Unmarshal nothing err, Failed")
+	}
+}
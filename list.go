@@ -0,0 +1,139 @@
+package goproc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/inhies/go-bytesize"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// ListProcesses 全プロセスの一覧をマシン全体の情報と一緒に返す。CPU使用率と1秒あたりのI/Oは全プロセスまとめて1秒計測する
+// 全プロセスを見るのでGetProcessより項目を絞っている(環境変数、リソース制限、ソケット、子プロセスの集計は取らない)
+func ListProcesses() (*Listing, error) {
+	pids, err := allPids()
+	if err != nil {
+		return nil, err
+	}
+	s := primeSampler(pids)
+	return s.newListing(s.listProcesses(pids))
+}
+
+// ListProcesses ListProcessesと同じ。CPU使用率と1秒あたりのI/Oは前回呼び出した時からの値になる
+func (s *Sampler) ListProcesses() (*Listing, error) {
+	pids, err := allPids()
+	if err != nil {
+		return nil, err
+	}
+	return s.newListing(s.listProcesses(pids))
+}
+
+// allPids 全プロセスのPIDを返す
+func allPids() ([]int, error) {
+	pids, err := process.Pids()
+	if err != nil {
+		return nil, err
+	}
+	ret := []int{}
+	for _, pid := range pids {
+		ret = append(ret, int(pid))
+	}
+	return ret, nil
+}
+
+// primeSampler 1プロセスずつ1秒ブロッキングしないように、全部のCPU時間とI/Oを取ってから1秒待ったSamplerを返す
+func primeSampler(pids []int) *Sampler {
+	s := NewSampler()
+	for _, pid := range pids {
+		p, err := process.NewProcess(int32(pid))
+		if err != nil {
+			continue
+		}
+		s.cpuPercent(p)
+		if io, err := getIOCounters(pid); err == nil {
+			s.ioRates(p, "io", io)
+		}
+	}
+	if len(pids) > 0 {
+		time.Sleep(1 * time.Second)
+	}
+	return s
+}
+
+// listProcesses 取得できたプロセスだけ返す(終了したプロセスや権限が無いプロセスはスキップする)
+func (s *Sampler) listProcesses(pids []int) Processes {
+	ret := Processes{}
+	for _, pid := range pids {
+		p, err := getProcessSummary(pid, s)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, *p)
+	}
+	return ret
+}
+
+// getProcessSummary 一覧用のプロセス情報を返す。全プロセス分取るのでエラーはログに出さない
+func getProcessSummary(pid int, s *Sampler) (*Process, error) {
+	// GetProcessと同じく0と1は扱わない
+	if pid <= 1 {
+		return nil, errors.New("pid is out of range")
+	}
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return nil, err
+	}
+	ret := &Process{Pid: pid, Exist: true}
+	ret.Name, err = p.Name()
+	if err != nil {
+		return nil, err
+	}
+
+	ret.CpuPercent, _ = s.cpuPercent(p)
+	if cputime, err := p.Times(); err == nil {
+		ret.CpuTotal = cputime.Total()
+		ret.CpuUser = cputime.User
+		ret.CpuSystem = cputime.System
+	}
+	if memory, err := p.MemoryInfo(); err == nil {
+		ret.Vms = bytesize.New(float64(memory.VMS)).String()
+		ret.Rss = bytesize.New(float64(memory.RSS)).String()
+		ret.Swap = bytesize.New(float64(memory.Swap)).String()
+		ret.MemPercent = memPercent(memory.RSS)
+	}
+	ret.Memory, _ = getMemoryDetail(pid)
+
+	ret.Cmdline, _ = p.Cmdline()
+	ret.Exe, _ = p.Exe()
+	ret.Cwd, _ = p.Cwd()
+
+	if ret.IO, err = getIOCounters(pid); err == nil {
+		ret.IORates = s.ioRates(p, "io", ret.IO)
+	}
+	numthreads, _ := p.NumThreads()
+	ret.NumThreads = int(numthreads)
+	numfds, _ := p.NumFDs()
+	ret.NumFDs = int(numfds)
+
+	ret.Cgroup, _ = getCgroupInfo(pid)
+	ret.Nice, ret.SchedPolicy, ret.CpusAllowed, _ = getSchedule(pid)
+
+	if createtime, err := p.CreateTime(); err == nil && createtime > 0 {
+		ret.StartTime = time.Unix(createtime/1000, (createtime%1000)*int64(time.Millisecond))
+		ret.Uptime = time.Since(ret.StartTime)
+		if CreateTimeLayout != "" {
+			ret.CreateTime = ret.FormatStartTime(CreateTimeLayout, CreateTimeLocation)
+		}
+	}
+	ret.Status, _, _ = getStatus(p)
+
+	ppid, _ := p.Ppid()
+	ret.Ppid = int(ppid)
+	ret.Owner, _ = getOwner(p)
+	ret.Pgid, ret.Sid, ret.TtyNr, _ = getSession(pid)
+	if ret.TtyNr != 0 {
+		ret.Terminal, _ = p.Terminal()
+	}
+
+	return ret, nil
+}
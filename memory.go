@@ -0,0 +1,47 @@
+package goproc
+
+// メモリの内訳(バイト単位)
+// RSSは共有ページをプロセス毎に数えるので、forkしたワーカーの合計はPSSかUSSで見る
+type MemoryDetail struct {
+	Rss uint64 `json:"rss"`
+	// 共有ページを共有しているプロセス数で按分したサイズ
+	Pss uint64 `json:"pss"`
+	// そのプロセスだけが使っているサイズ(Private_Clean + Private_Dirty)
+	Uss          uint64 `json:"uss"`
+	SharedClean  uint64 `json:"sharedClean"`
+	SharedDirty  uint64 `json:"sharedDirty"`
+	PrivateClean uint64 `json:"privateClean"`
+	PrivateDirty uint64 `json:"privateDirty"`
+	Anonymous    uint64 `json:"anonymous"`
+	Swap         uint64 `json:"swap"`
+	SwapPss      uint64 `json:"swapPss"`
+}
+
+// add 子プロセスのメモリを足し込む
+func (m *MemoryDetail) add(o *MemoryDetail) {
+	m.Rss += o.Rss
+	m.Pss += o.Pss
+	m.Uss += o.Uss
+	m.SharedClean += o.SharedClean
+	m.SharedDirty += o.SharedDirty
+	m.PrivateClean += o.PrivateClean
+	m.PrivateDirty += o.PrivateDirty
+	m.Anonymous += o.Anonymous
+	m.Swap += o.Swap
+	m.SwapPss += o.SwapPss
+}
+
+// getTreeMemoryDetail 自プロセスと全子孫プロセス(descs)のメモリの内訳の合計を返す
+// 権限が無くて読めない子孫は合計に含めない
+func getTreeMemoryDetail(descs []int, self *MemoryDetail) *MemoryDetail {
+	ret := &MemoryDetail{}
+	if self != nil {
+		ret.add(self)
+	}
+	for _, d := range descs {
+		if m, err := getMemoryDetail(d); err == nil {
+			ret.add(m)
+		}
+	}
+	return ret
+}
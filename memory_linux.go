@@ -0,0 +1,63 @@
+package goproc
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// getMemoryDetail /proc/<pid>/smaps_rollupからメモリの内訳を取得する
+// smaps_rollupが無い古いカーネル(4.14より前)は/proc/<pid>/smapsを合計する
+func getMemoryDetail(pid int) (*MemoryDetail, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/smaps_rollup", pid))
+	if os.IsNotExist(err) {
+		b, err = os.ReadFile(fmt.Sprintf("/proc/%d/smaps", pid))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseSmaps(string(b)), nil
+}
+
+// parseSmaps smaps_rollupかsmapsをパースする。smapsはマッピング毎の値を合計する
+func parseSmaps(content string) *MemoryDetail {
+	ret := &MemoryDetail{}
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) != 2 || fields[1] != "kB" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		n := kb * 1024
+		switch key {
+		case "Rss":
+			ret.Rss += n
+		case "Pss":
+			ret.Pss += n
+		case "Shared_Clean":
+			ret.SharedClean += n
+		case "Shared_Dirty":
+			ret.SharedDirty += n
+		case "Private_Clean":
+			ret.PrivateClean += n
+		case "Private_Dirty":
+			ret.PrivateDirty += n
+		case "Anonymous":
+			ret.Anonymous += n
+		case "Swap":
+			ret.Swap += n
+		case "SwapPss":
+			ret.SwapPss += n
+		}
+	}
+	ret.Uss = ret.PrivateClean + ret.PrivateDirty
+	return ret
+}
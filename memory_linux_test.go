@@ -0,0 +1,62 @@
+package goproc
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseSmaps(t *testing.T) {
+	rollup := parseSmaps(`564387f3b000-7ffde8c54000 ---p 00000000 00:00 0                          [rollup]
+Rss:                1408 kB
+Pss:                 473 kB
+Pss_Anon:            100 kB
+Shared_Clean:       1268 kB
+Shared_Dirty:          0 kB
+Private_Clean:        40 kB
+Private_Dirty:       100 kB
+Anonymous:           100 kB
+Swap:                  8 kB
+SwapPss:               4 kB
+`)
+	except := MemoryDetail{Rss: 1408 * 1024, Pss: 473 * 1024, Uss: 140 * 1024, SharedClean: 1268 * 1024, PrivateClean: 40 * 1024, PrivateDirty: 100 * 1024, Anonymous: 100 * 1024, Swap: 8 * 1024, SwapPss: 4 * 1024}
+	if *rollup != except {
+		t.Errorf("parseSmaps = %#v, expect = %#v, Failed", *rollup, except)
+	}
+
+	// smaps_rollupが無い時はマッピング毎の値を合計する
+	smaps := parseSmaps(`00400000-0040b000 r-xp 00000000 fd:01 1234 /usr/bin/sleep
+Rss:                  12 kB
+Pss:                   6 kB
+Private_Dirty:         4 kB
+VmFlags: rd ex mr mw me dw
+7f0000000000-7f0000021000 rw-p 00000000 00:00 0
+Rss:                   8 kB
+Pss:                   8 kB
+Private_Dirty:         8 kB
+`)
+	if smaps.Rss != 20*1024 || smaps.Pss != 14*1024 || smaps.Uss != 12*1024 {
+		t.Errorf("parseSmaps = %#v, Failed", *smaps)
+	}
+}
+
+func TestGetMemoryDetail(t *testing.T) {
+	// 子プロセスのPSSも合計に入ること
+	startSleep(t, "5")
+
+	self, err := getMemoryDetail(os.Getpid())
+	if err != nil {
+		t.Fatalf("getMemoryDetail = %s, Failed", err)
+	}
+	if self.Pss == 0 || self.Rss < self.Pss || self.Uss == 0 {
+		t.Errorf("getMemoryDetail = %#v, Failed", self)
+	}
+
+	tree, err := processTree()
+	if err != nil {
+		t.Fatalf("processTree = %s, Failed", err)
+	}
+	sum := getTreeMemoryDetail(descendants(os.Getpid(), tree), self)
+	if sum.Pss <= self.Pss {
+		t.Errorf("getTreeMemoryDetail = %#v, Failed", sum)
+	}
+}
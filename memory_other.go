@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+// getMemoryDetail メモリの内訳はLinuxのみ対応
+func getMemoryDetail(pid int) (*MemoryDetail, error) {
+	return nil, ErrNotSupported
+}
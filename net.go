@@ -0,0 +1,106 @@
+package goproc
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+)
+
+// CollectListeningPorts GetProcessでListeningPortsを取るか
+// Linux以外はgopsutilがlsof等のコマンドを起動して重いので既定では取らない(GetConnectionsで個別に取れる)
+var CollectListeningPorts = collectListeningPortsDefault
+
+// プロセスが持っているソケットの情報
+type Connection struct {
+	Fd int `json:"fd"`
+	// tcp, tcp6, udp, udp6, unix
+	Proto      string `json:"proto"`
+	LocalAddr  string `json:"localAddr"`
+	RemoteAddr string `json:"remoteAddr"`
+	// LISTEN, ESTABLISHED等。UDPは接続していなければCLOSE
+	State string `json:"state"`
+	Inode uint64 `json:"inode"`
+}
+
+// 待ち受けているポート
+type ListeningPort struct {
+	// tcp, tcp6, udp, udp6
+	Proto string `json:"proto"`
+	Addr  string `json:"addr"`
+	Port  int    `json:"port"`
+}
+
+// GetConnections 指定されたPIDが持っているTCP/UDP/Unixソケットの一覧を返す
+func GetConnections(pid int) ([]Connection, error) {
+	// 渡されたpidがマイナス、0、1の時はエラーで返す(そうじゃないとPanicになる)
+	if pid <= 1 {
+		return nil, fmt.Errorf("Don't get process, when pid is %d", pid)
+	}
+	return getConnections(pid)
+}
+
+// FindProcessByPort 指定されたポートで待ち受けているプロセスを返す。protoはtcpかudp(IPv6も含む)
+func FindProcessByPort(port int, proto string) (Processes, error) {
+	if proto != "tcp" && proto != "udp" {
+		return nil, fmt.Errorf("proto must be tcp or udp: %s", proto)
+	}
+	pids, err := findPidsByPort(port, proto)
+	if err != nil {
+		return nil, err
+	}
+	return GetProcesses(pids)
+}
+
+// listeningPorts ソケットの一覧から待ち受けているポートを取り出す
+func listeningPorts(conns []Connection) []ListeningPort {
+	ret := []ListeningPort{}
+	seen := map[ListeningPort]bool{}
+	for _, c := range conns {
+		if !isListening(c) {
+			continue
+		}
+		addr, port, err := splitHostPort(c.LocalAddr)
+		if err != nil {
+			continue
+		}
+		lp := ListeningPort{Proto: c.Proto, Addr: addr, Port: port}
+		if seen[lp] {
+			continue
+		}
+		seen[lp] = true
+		ret = append(ret, lp)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Port != ret[j].Port {
+			return ret[i].Port < ret[j].Port
+		}
+		return ret[i].Proto < ret[j].Proto
+	})
+	return ret
+}
+
+// isListening TCPはLISTEN、UDPは接続先が無いものを待ち受けと見なす
+func isListening(c Connection) bool {
+	switch c.Proto {
+	case "tcp", "tcp6":
+		return c.State == "LISTEN"
+	case "udp", "udp6":
+		_, port, err := splitHostPort(c.RemoteAddr)
+		return err == nil && port == 0
+	}
+	return false
+}
+
+// splitHostPort "127.0.0.1:8080"や"[::1]:8080"をアドレスとポートに分ける
+func splitHostPort(addr string) (string, int, error) {
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return "", 0, err
+	}
+	return host, port, nil
+}
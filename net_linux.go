@@ -0,0 +1,224 @@
+package goproc
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Linuxは/procを読むだけなので既定でListeningPortsを取る
+const collectListeningPortsDefault = true
+
+// /proc/net/tcpのstの値
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+}
+
+// /proc/net/unixのFlagsで待ち受けを表すビット(__SO_ACCEPTCON)
+const unixAcceptCon = 0x10000
+
+// getConnections /proc/<pid>/fdのソケットのinodeと/proc/<pid>/net/*を突き合わせる
+// /proc/<pid>/netを読むのでコンテナ等の別のネットワーク名前空間のプロセスでも取れる
+func getConnections(pid int) ([]Connection, error) {
+	inodes, err := socketInodes(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []Connection{}
+	if len(inodes) == 0 {
+		return ret, nil
+	}
+	for _, proto := range []string{"tcp", "tcp6", "udp", "udp6", "unix"} {
+		conns, err := readProcNet(fmt.Sprintf("/proc/%d/net/%s", pid, proto), proto)
+		if err != nil {
+			// IPv6が無効な環境などファイルが無いことがある
+			continue
+		}
+		for _, c := range conns {
+			if fd, ok := inodes[c.Inode]; ok {
+				c.Fd = fd
+				ret = append(ret, c)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// socketInodes 指定されたPIDが持っているソケットのinodeとファイルディスクリプタの対応を返す
+func socketInodes(pid int) (map[uint64]int, error) {
+	dir := fmt.Sprintf("/proc/%d/fd", pid)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[uint64]int{}
+	for _, e := range entries {
+		target, err := os.Readlink(filepath.Join(dir, e.Name()))
+		if err != nil || !strings.HasPrefix(target, "socket:[") {
+			continue
+		}
+		inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]"), 10, 64)
+		if err != nil {
+			continue
+		}
+		fd, _ := strconv.Atoi(e.Name())
+		ret[inode] = fd
+	}
+	return ret, nil
+}
+
+// readProcNet /proc/net/tcp等を読んでパースする
+func readProcNet(file, proto string) ([]Connection, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if proto == "unix" {
+		return parseProcNetUnix(string(b)), nil
+	}
+	return parseProcNetInet(string(b), proto), nil
+}
+
+// parseProcNetInet /proc/net/tcp、udp(IPv6含む)をパースする
+func parseProcNetInet(content, proto string) []Connection {
+	ret := []Connection{}
+	lines := strings.Split(content, "\n")
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+		local, err := decodeInetAddr(fields[1])
+		if err != nil {
+			continue
+		}
+		remote, err := decodeInetAddr(fields[2])
+		if err != nil {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			continue
+		}
+		state, ok := tcpStates[fields[3]]
+		if !ok {
+			state = fields[3]
+		}
+		ret = append(ret, Connection{Proto: proto, LocalAddr: local, RemoteAddr: remote, State: state, Inode: inode})
+	}
+	return ret
+}
+
+// decodeInetAddr "0100007F:1F90"のような16進のアドレスを"127.0.0.1:8080"にする
+// アドレスは32bit毎にホストのバイトオーダーの数値として16進で書かれている(x86ならバイトが逆順になる)
+func decodeInetAddr(s string) (string, error) {
+	h, p, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", fmt.Errorf("invalid address: %s", s)
+	}
+	raw, err := hex.DecodeString(h)
+	if err != nil || (len(raw) != 4 && len(raw) != 16) {
+		return "", fmt.Errorf("invalid address: %s", s)
+	}
+	port, err := strconv.ParseUint(p, 16, 16)
+	if err != nil {
+		return "", fmt.Errorf("invalid port: %s", s)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		nativeEndian.PutUint32(ip[i:], binary.BigEndian.Uint32(raw[i:]))
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(port))), nil
+}
+
+// parseProcNetUnix /proc/net/unixをパースする
+func parseProcNetUnix(content string) []Connection {
+	ret := []Connection{}
+	lines := strings.Split(content, "\n")
+	for _, line := range lines[1:] {
+		// Num RefCount Protocol Flags Type St Inode Path
+		fields := strings.Fields(line)
+		if len(fields) < 7 {
+			continue
+		}
+		flags, _ := strconv.ParseUint(fields[3], 16, 32)
+		inode, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			continue
+		}
+		c := Connection{Proto: "unix", Inode: inode}
+		if len(fields) > 7 {
+			c.LocalAddr = fields[7]
+		}
+		switch {
+		case flags&unixAcceptCon != 0:
+			c.State = "LISTEN"
+		case fields[5] == "03":
+			c.State = "ESTABLISHED"
+		default:
+			c.State = "NONE"
+		}
+		ret = append(ret, c)
+	}
+	return ret
+}
+
+// findPidsByPort 自分のネットワーク名前空間で指定されたポートを待ち受けているソケットを持つPIDを返す
+func findPidsByPort(port int, proto string) ([]int, error) {
+	inodes := map[uint64]bool{}
+	for _, p := range []string{proto, proto + "6"} {
+		conns, err := readProcNet("/proc/net/"+p, p)
+		if err != nil {
+			continue
+		}
+		for _, c := range conns {
+			_, lport, err := splitHostPort(c.LocalAddr)
+			if err == nil && lport == port && isListening(c) {
+				inodes[c.Inode] = true
+			}
+		}
+	}
+	if len(inodes) == 0 {
+		return []int{}, nil
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	pids := []int{}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		// 他のユーザーのプロセスは権限が無いと読めないのでスキップされる
+		socks, err := socketInodes(pid)
+		if err != nil {
+			continue
+		}
+		for inode := range socks {
+			if inodes[inode] {
+				pids = append(pids, pid)
+				break
+			}
+		}
+	}
+	return pids, nil
+}
@@ -0,0 +1,85 @@
+package goproc
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+func TestDecodeInetAddr(t *testing.T) {
+	cases := []struct {
+		in     string
+		except string
+	}{
+		{"0100007F:1F90", "127.0.0.1:8080"},
+		{"00000000:0050", "0.0.0.0:80"},
+		{"00000000000000000000000001000000:1F90", "[::1]:8080"},
+		{"0000000000000000FFFF00000100007F:01BB", "127.0.0.1:443"},
+	}
+	for _, c := range cases {
+		addr, err := decodeInetAddr(c.in)
+		if err != nil || addr != c.except {
+			t.Errorf("decodeInetAddr(%s) = %s, %v, expect = %s, Failed", c.in, addr, err, c.except)
+		}
+	}
+	if _, err := decodeInetAddr("0100007F"); err == nil {
+		t.Errorf("decodeInetAddr nothing err, Failed")
+	}
+}
+
+func TestParseProcNet(t *testing.T) {
+	tcp := parseProcNetInet(`  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 923 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:C350 01 00000000:00000000 00:00000000 00000000  1000        0 924 1 0000000000000000 20 4 30 10 -1
+`, "tcp")
+	if len(tcp) != 2 || tcp[0].State != "LISTEN" || tcp[0].Inode != 923 || tcp[1].RemoteAddr != "127.0.0.1:50000" {
+		t.Errorf("parseProcNetInet = %#v, Failed", tcp)
+	}
+
+	unix := parseProcNetUnix(`Num       RefCount Protocol Flags    Type St Inode Path
+0000000000000000: 00000002 00000000 00010000 0001 01 17523 /run/jetty.sock
+0000000000000000: 00000003 00000000 00000000 0001 03   921
+`)
+	if len(unix) != 2 || unix[0].State != "LISTEN" || unix[0].LocalAddr != "/run/jetty.sock" || unix[1].State != "ESTABLISHED" {
+		t.Errorf("parseProcNetUnix = %#v, Failed", unix)
+	}
+
+	ports := listeningPorts(append(tcp, Connection{Proto: "udp", LocalAddr: "0.0.0.0:53", RemoteAddr: "0.0.0.0:0"}))
+	if len(ports) != 2 || ports[0].Port != 53 || ports[1].Port != 8080 || ports[1].Addr != "127.0.0.1" {
+		t.Errorf("listeningPorts = %#v, Failed", ports)
+	}
+}
+
+func TestGetConnections(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can't listen: %v", err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	conns, err := GetConnections(os.Getpid())
+	if err != nil {
+		t.Fatalf("GetConnections = %s, Failed", err)
+	}
+	found := false
+	for _, p := range listeningPorts(conns) {
+		if p.Proto == "tcp" && p.Port == port {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("GetConnections = %#v, port %d is not found, Failed", conns, port)
+	}
+
+	ps, err := FindProcessByPort(port, "tcp")
+	if err != nil {
+		t.Fatalf("FindProcessByPort = %s, Failed", err)
+	}
+	if len(ps) != 1 || ps[0].Pid != os.Getpid() {
+		t.Errorf("FindProcessByPort = %d processes, Failed", len(ps))
+	}
+	if _, err := FindProcessByPort(port, "sctp"); err == nil {
+		t.Errorf("FindProcessByPort nothing err, Failed")
+	}
+}
@@ -0,0 +1,78 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"net"
+	"strconv"
+	"syscall"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+// gopsutilがlsof等を起動するので既定ではListeningPortsを取らない
+const collectListeningPortsDefault = false
+
+// getConnections gopsutilで取れる範囲で一覧を作る。inodeは0
+func getConnections(pid int) ([]Connection, error) {
+	stats, err := psnet.ConnectionsPid("all", int32(pid))
+	if err != nil {
+		return nil, err
+	}
+	ret := []Connection{}
+	for _, s := range stats {
+		ret = append(ret, connectionFromStat(s))
+	}
+	return ret, nil
+}
+
+// connectionFromStat gopsutilのソケット情報を変換する
+func connectionFromStat(s psnet.ConnectionStat) Connection {
+	proto := "unix"
+	switch {
+	case s.Type == syscall.SOCK_STREAM && s.Family == syscall.AF_INET:
+		proto = "tcp"
+	case s.Type == syscall.SOCK_STREAM && s.Family == syscall.AF_INET6:
+		proto = "tcp6"
+	case s.Type == syscall.SOCK_DGRAM && s.Family == syscall.AF_INET:
+		proto = "udp"
+	case s.Type == syscall.SOCK_DGRAM && s.Family == syscall.AF_INET6:
+		proto = "udp6"
+	}
+	c := Connection{Fd: int(s.Fd), Proto: proto, State: s.Status}
+	if proto == "unix" {
+		c.LocalAddr = s.Laddr.IP
+		return c
+	}
+	c.LocalAddr = joinHostPort(s.Laddr.IP, s.Laddr.Port)
+	c.RemoteAddr = joinHostPort(s.Raddr.IP, s.Raddr.Port)
+	return c
+}
+
+// findPidsByPort gopsutilで全ソケットを取得して指定されたポートで待ち受けているPIDを返す
+func findPidsByPort(port int, proto string) ([]int, error) {
+	stats, err := psnet.Connections(proto)
+	if err != nil {
+		return nil, err
+	}
+	pids := []int{}
+	seen := map[int]bool{}
+	for _, s := range stats {
+		c := connectionFromStat(s)
+		if int(s.Laddr.Port) != port || !isListening(c) || seen[int(s.Pid)] {
+			continue
+		}
+		seen[int(s.Pid)] = true
+		pids = append(pids, int(s.Pid))
+	}
+	return pids, nil
+}
+
+// joinHostPort アドレスとポートを"127.0.0.1:8080"や"[::1]:8080"にする
+func joinHostPort(host string, port uint32) string {
+	if host == "" {
+		host = "*"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
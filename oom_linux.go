@@ -0,0 +1,88 @@
+package goproc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// applyOOMScoreAdj プロセスのoom_score_adjを設定する(-1000から1000、下げるにはCAP_SYS_RESOURCEが必要)
+// StartServiceではstartCmdでexec直後に止めている間に設定するので、起動中にforkした子にも引き継がれる
+func applyOOMScoreAdj(pid int, adj *int) error {
+	if adj == nil {
+		return nil
+	}
+	if *adj < -1000 || *adj > 1000 {
+		return fmt.Errorf("oom_score_adj must be between -1000 and 1000: %d", *adj)
+	}
+	file := fmt.Sprintf("/proc/%d/oom_score_adj", pid)
+	if err := os.WriteFile(file, []byte(strconv.Itoa(*adj)), 0644); err != nil {
+		return fmt.Errorf("write oom_score_adj: %w", err)
+	}
+	return nil
+}
+
+// oomScoreAdjChanged 起動元と違うoom_score_adjか。同じ値ならforkした子プロセスに引き継がれるので設定しなくてよい
+func oomScoreAdjChanged(adj *int) bool {
+	if adj == nil {
+		return false
+	}
+	cur, err := readProcInt(os.Getpid(), "oom_score_adj")
+	return err != nil || cur != *adj
+}
+
+// getOOMScore 指定されたPIDのoom_scoreとoom_score_adjを返す
+func getOOMScore(pid int) (int, int, error) {
+	score, err := readProcInt(pid, "oom_score")
+	if err != nil {
+		return 0, 0, err
+	}
+	adj, err := readProcInt(pid, "oom_score_adj")
+	if err != nil {
+		return 0, 0, err
+	}
+	return score, adj, nil
+}
+
+// readProcInt /proc/<pid>/以下の数値1つだけのファイルを読む
+func readProcInt(pid int, name string) (int, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/%s", pid, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// oomKillCount OOM Killerで殺されたプロセス数を返す
+// サービスのcgroupがあればmemory.events、無ければホスト全体の/proc/vmstatのカウンターを使う
+func oomKillCount(cg *cgroup) uint64 {
+	if cg != nil {
+		if n, err := readCgroupStat(filepath.Join(cg.dir, "memory.events"), "oom_kill"); err == nil {
+			return n
+		}
+	}
+	n, _ := readCgroupStat("/proc/vmstat", "oom_kill")
+	return n
+}
+
+// classifyExit SIGKILLで終了していて、起動してからOOM Killerのカウンターが増えていればErrOOMKilledにする
+// cgroupが無い時はホスト全体のカウンターなので、同時期に他のプロセスがOOMで殺されると誤判定することがある
+func classifyExit(err error, before uint64, cg *cgroup) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() || status.Signal() != syscall.SIGKILL {
+		return err
+	}
+	if oomKillCount(cg) > before {
+		return fmt.Errorf("%w: %v", ErrOOMKilled, err)
+	}
+	return err
+}
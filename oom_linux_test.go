@@ -0,0 +1,80 @@
+package goproc
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyOOMScoreAdj(t *testing.T) {
+	cmd := startSleep(t, "5")
+
+	adj := 500
+	if err := applyOOMScoreAdj(cmd.Process.Pid, &adj); err != nil {
+		t.Fatalf("applyOOMScoreAdj = %s, Failed", err)
+	}
+	score, got, err := getOOMScore(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("getOOMScore = %s, Failed", err)
+	}
+	if got != 500 || score < 500 {
+		t.Errorf("getOOMScore = %d, %d, Failed", score, got)
+	}
+
+	adj = 1001
+	if err := applyOOMScoreAdj(cmd.Process.Pid, &adj); err == nil {
+		t.Errorf("applyOOMScoreAdj nothing err, Failed")
+	}
+}
+
+func TestStartCmdOOMScoreAdj(t *testing.T) {
+	// プログラムの最初からoom_score_adjが設定されていること
+	cmd := exec.Command("cat", "/proc/self/oom_score_adj")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adj := 500
+	if _, err := startCmd(cmd, nil, func(pid int) error { return applyOOMScoreAdj(pid, &adj) }); err != nil {
+		t.Fatalf("startCmd = %s, Failed", err)
+	}
+	b, _ := io.ReadAll(out)
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("cat = %s, Failed", err)
+	}
+	if got := strings.TrimSpace(string(b)); got != "500" {
+		t.Errorf("oom_score_adj = %s, expect = 500, Failed", got)
+	}
+}
+
+func TestClassifyExit(t *testing.T) {
+	cmd := startSleep(t, "5")
+	cmd.Process.Kill()
+	killed := cmd.Wait()
+
+	// memory.eventsのoom_killが増えていればOOM Killerと見なす
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "memory.events"), []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cg := &cgroup{dir: dir}
+
+	if err := classifyExit(killed, 0, cg); !errors.Is(err, ErrOOMKilled) {
+		t.Errorf("classifyExit = %v, expect ErrOOMKilled, Failed", err)
+	}
+	if err := classifyExit(killed, 1, cg); errors.Is(err, ErrOOMKilled) {
+		t.Errorf("classifyExit = %v, expect manual SIGKILL, Failed", err)
+	}
+
+	exited := exec.Command("sh", "-c", "exit 3").Run()
+	if err := classifyExit(exited, 0, cg); errors.Is(err, ErrOOMKilled) || err != exited {
+		t.Errorf("classifyExit = %v, expect exit status 3, Failed", err)
+	}
+	if err := classifyExit(nil, 0, cg); err != nil {
+		t.Errorf("classifyExit = %v, expect nil, Failed", err)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"fmt"
+)
+
+// applyOOMScoreAdj oom_score_adjはLinuxのみ対応
+func applyOOMScoreAdj(pid int, adj *int) error {
+	if adj == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: oom_score_adj", ErrNotSupported)
+}
+
+// oomScoreAdjChanged 起動元の値と比べられないので指定があれば設定する(applyOOMScoreAdjがエラーを返す)
+func oomScoreAdjChanged(adj *int) bool {
+	return adj != nil
+}
+
+// getOOMScore oom_scoreはLinuxのみ対応
+func getOOMScore(pid int) (int, int, error) {
+	return 0, 0, ErrNotSupported
+}
+
+func oomKillCount(cg *cgroup) uint64 {
+	return 0
+}
+
+// classifyExit OOM Killerの判定はLinuxのみ対応
+func classifyExit(err error, before uint64, cg *cgroup) error {
+	return err
+}
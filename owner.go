@@ -0,0 +1,112 @@
+package goproc
+
+import (
+	"os/user"
+	"strconv"
+	"sync"
+)
+
+// プロセスの所有者。取得できないIDは-1
+type Owner struct {
+	Uid            int    `json:"uid"`
+	Euid           int    `json:"euid"`
+	Suid           int    `json:"suid"`
+	Gid            int    `json:"gid"`
+	Egid           int    `json:"egid"`
+	Sgid           int    `json:"sgid"`
+	User           string `json:"user"`
+	EffectiveUser  string `json:"effectiveUser"`
+	Group          string `json:"group"`
+	EffectiveGroup string `json:"effectiveGroup"`
+}
+
+// 所有者・セッションでプロセスを絞り込む条件。ゼロ値の項目は条件にしない
+type OwnerFilter struct {
+	// 実ユーザーか実効ユーザーの名前またはUID
+	User string `json:"user"`
+	// 実グループか実効グループの名前またはGID
+	Group string `json:"group"`
+	Pgid  int    `json:"pgid"`
+	Sid   int    `json:"sid"`
+	// pts/0のような端末名
+	Terminal string `json:"terminal"`
+}
+
+// UID、GIDから名前を引いた結果のキャッシュ(プロセス一覧で何度も引くため)
+var userNames, groupNames sync.Map
+
+// lookupUserName UIDからユーザー名を返す。見つからなければUIDの文字列
+func lookupUserName(uid int) string {
+	if uid < 0 {
+		return ""
+	}
+	if v, ok := userNames.Load(uid); ok {
+		return v.(string)
+	}
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	userNames.Store(uid, name)
+	return name
+}
+
+// lookupGroupName GIDからグループ名を返す。見つからなければGIDの文字列
+func lookupGroupName(gid int) string {
+	if gid < 0 {
+		return ""
+	}
+	if v, ok := groupNames.Load(gid); ok {
+		return v.(string)
+	}
+	name := strconv.Itoa(gid)
+	if g, err := user.LookupGroupId(name); err == nil {
+		name = g.Name
+	}
+	groupNames.Store(gid, name)
+	return name
+}
+
+// Match プロセスが条件に合うか判定する
+func (f OwnerFilter) Match(p *Process) bool {
+	if f.User != "" {
+		if p.Owner == nil || !matchIdOrName(f.User, p.Owner.Uid, p.Owner.User, p.Owner.Euid, p.Owner.EffectiveUser) {
+			return false
+		}
+	}
+	if f.Group != "" {
+		if p.Owner == nil || !matchIdOrName(f.Group, p.Owner.Gid, p.Owner.Group, p.Owner.Egid, p.Owner.EffectiveGroup) {
+			return false
+		}
+	}
+	if f.Pgid != 0 && p.Pgid != f.Pgid {
+		return false
+	}
+	if f.Sid != 0 && p.Sid != f.Sid {
+		return false
+	}
+	if f.Terminal != "" && p.Terminal != f.Terminal {
+		return false
+	}
+	return true
+}
+
+// matchIdOrName 名前かIDが実・実効のどちらかと一致するか
+func matchIdOrName(want string, id int, name string, eid int, ename string) bool {
+	if want == name || want == ename {
+		return true
+	}
+	n, err := strconv.Atoi(want)
+	return err == nil && n >= 0 && (n == id || n == eid)
+}
+
+// FilterByOwner 所有者・セッションの条件に合うプロセスだけを返す
+func FilterByOwner(ps Processes, f OwnerFilter) Processes {
+	ret := Processes{}
+	for i := range ps {
+		if f.Match(&ps[i]) {
+			ret = append(ret, ps[i])
+		}
+	}
+	return ret
+}
@@ -0,0 +1,64 @@
+package goproc
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// getOwner /proc/<pid>/statusのUid、Gid(実、実効、保存、ファイルシステム)から所有者を返す
+func getOwner(p *process.Process) (*Owner, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", p.Pid))
+	if err != nil {
+		return nil, err
+	}
+	o := &Owner{Uid: -1, Euid: -1, Suid: -1, Gid: -1, Egid: -1, Sgid: -1}
+	for _, line := range strings.Split(string(b), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		ids := []int{}
+		for _, f := range strings.Fields(value) {
+			n, err := strconv.Atoi(f)
+			if err != nil {
+				break
+			}
+			ids = append(ids, n)
+		}
+		if len(ids) < 3 {
+			continue
+		}
+		switch key {
+		case "Uid":
+			o.Uid, o.Euid, o.Suid = ids[0], ids[1], ids[2]
+		case "Gid":
+			o.Gid, o.Egid, o.Sgid = ids[0], ids[1], ids[2]
+		}
+	}
+	o.User = lookupUserName(o.Uid)
+	o.EffectiveUser = lookupUserName(o.Euid)
+	o.Group = lookupGroupName(o.Gid)
+	o.EffectiveGroup = lookupGroupName(o.Egid)
+	return o, nil
+}
+
+// getSession /proc/<pid>/statからプロセスグループID、セッションID、制御端末のデバイス番号を返す
+func getSession(pid int) (int, int, int, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	// statの5番目がpgrp、6番目がsession、7番目がtty_nr(procStatFieldsは3番目が先頭)
+	fields := procStatFields(string(b))
+	if len(fields) < 5 {
+		return 0, 0, 0, fmt.Errorf("invalid /proc/%d/stat", pid)
+	}
+	pgid, _ := strconv.Atoi(fields[5-3])
+	sid, _ := strconv.Atoi(fields[6-3])
+	tty, _ := strconv.Atoi(fields[7-3])
+	return pgid, sid, tty, nil
+}
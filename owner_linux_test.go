@@ -0,0 +1,40 @@
+package goproc
+
+import (
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestGetOwnerSession(t *testing.T) {
+	pid := os.Getpid()
+	p, err := GetProcess(pid)
+	if err != nil {
+		t.Fatalf("GetProcess = %s, Failed", err)
+	}
+	if p.Owner == nil {
+		t.Fatal("Owner = nil, Failed")
+	}
+	if p.Owner.Uid != os.Getuid() || p.Owner.Euid != os.Geteuid() || p.Owner.Gid != os.Getgid() {
+		t.Errorf("Owner = %#v, Failed", p.Owner)
+	}
+	if p.Owner.User == "" || p.Owner.Group == "" {
+		t.Errorf("Owner name = %#v, Failed", p.Owner)
+	}
+	pgid, _ := unix.Getpgid(pid)
+	sid, _ := unix.Getsid(pid)
+	if p.Pgid != pgid || p.Sid != sid {
+		t.Errorf("Pgid, Sid = %d, %d, expect = %d, %d, Failed", p.Pgid, p.Sid, pgid, sid)
+	}
+
+	if got := FilterByOwner(Processes{*p}, OwnerFilter{User: p.Owner.User, Sid: sid}); len(got) != 1 {
+		t.Errorf("FilterByOwner(match) = %d, expect = 1, Failed", len(got))
+	}
+	if got := FilterByOwner(Processes{*p}, OwnerFilter{User: "-1"}); len(got) != 0 {
+		t.Errorf("FilterByOwner(uid -1) = %d, expect = 0, Failed", len(got))
+	}
+	if got := FilterByOwner(Processes{*p}, OwnerFilter{Pgid: pgid + 1}); len(got) != 0 {
+		t.Errorf("FilterByOwner(pgid) = %d, expect = 0, Failed", len(got))
+	}
+}
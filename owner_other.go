@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// getOwner Linux以外はgopsutilのUsername()で実効ユーザー名だけ取る。IDは-1
+func getOwner(p *process.Process) (*Owner, error) {
+	name, err := p.Username()
+	if err != nil {
+		return nil, err
+	}
+	return &Owner{Uid: -1, Euid: -1, Suid: -1, Gid: -1, Egid: -1, Sgid: -1, User: name, EffectiveUser: name}, nil
+}
+
+// getSession セッション情報はLinuxのみ対応
+func getSession(pid int) (int, int, int, error) {
+	return 0, 0, 0, ErrNotSupported
+}
@@ -0,0 +1,15 @@
+package goproc
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// pidfdOpen 指定されたPIDのpidfdを返す(Linux 5.3以降)。pidfdは常にclose-on-exec
+// 子プロセスでなくても終了をpollで待てて、後からPIDが再利用されても別のプロセスを指さない
+func pidfdOpen(pid int) (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_PIDFD_OPEN, uintptr(pid), 0, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
@@ -0,0 +1,575 @@
+package goproc
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// QueryExpr プロセスの絞り込み式
+//
+//	name =~ "java" && rss > 1GB && cpu > 50
+//	!(user == "root" || uptime < 10m)
+//
+// 比較演算子は==、!=、>、>=、<、<=、=~(正規表現)、!~、論理演算子は&&、||、!と括弧が使える
+// サイズの項目はB、KB、MB、GB、TB、PB、EB(1024倍)、時間の項目はms、s、m、h、dの単位を付けられる。単位が無ければバイトと秒
+// 数値は負の数も書ける(nice < -5)
+type QueryExpr struct {
+	src  string
+	root queryNode
+}
+
+// 式の項目の種類
+type queryKind int
+
+const (
+	queryString queryKind = iota
+	queryNumber
+	queryBytes
+	queryDuration
+)
+
+// 式で使える項目
+type queryField struct {
+	kind queryKind
+	str  func(p *Process) string
+	num  func(p *Process) float64
+}
+
+var queryFields = map[string]queryField{
+	"name":      {kind: queryString, str: func(p *Process) string { return p.Name }},
+	"cmdline":   {kind: queryString, str: func(p *Process) string { return p.Cmdline }},
+	"exe":       {kind: queryString, str: func(p *Process) string { return p.Exe }},
+	"cwd":       {kind: queryString, str: func(p *Process) string { return p.Cwd }},
+	"status":    {kind: queryString, str: func(p *Process) string { return p.Status }},
+	"terminal":  {kind: queryString, str: func(p *Process) string { return p.Terminal }},
+	"user":      {kind: queryString, str: processUser},
+	"group":     {kind: queryString, str: processGroup},
+	"cgroup":    {kind: queryString, str: func(p *Process) string { return cgroupString(p, func(c *CgroupInfo) string { return c.Path }) }},
+	"container": {kind: queryString, str: func(p *Process) string { return cgroupString(p, func(c *CgroupInfo) string { return c.ContainerID }) }},
+	"unit":      {kind: queryString, str: func(p *Process) string { return cgroupString(p, func(c *CgroupInfo) string { return c.SystemdUnit }) }},
+	"pid":       {kind: queryNumber, num: func(p *Process) float64 { return float64(p.Pid) }},
+	"ppid":      {kind: queryNumber, num: func(p *Process) float64 { return float64(p.Ppid) }},
+	"pgid":      {kind: queryNumber, num: func(p *Process) float64 { return float64(p.Pgid) }},
+	"sid":       {kind: queryNumber, num: func(p *Process) float64 { return float64(p.Sid) }},
+	"nice":      {kind: queryNumber, num: func(p *Process) float64 { return float64(p.Nice) }},
+	"threads":   {kind: queryNumber, num: func(p *Process) float64 { return float64(p.NumThreads) }},
+	"fds":       {kind: queryNumber, num: func(p *Process) float64 { return float64(p.NumFDs) }},
+	"cpu":       {kind: queryNumber, num: func(p *Process) float64 { return p.CpuPercent }},
+	"mem":       {kind: queryNumber, num: func(p *Process) float64 { return p.MemPercent }},
+	"cputime":   {kind: queryDuration, num: func(p *Process) float64 { return p.CpuTotal }},
+	"uptime":    {kind: queryDuration, num: func(p *Process) float64 { return p.Uptime.Seconds() }},
+	"rss":       {kind: queryBytes, num: func(p *Process) float64 { return float64(rssBytes(p)) }},
+	"vms":       {kind: queryBytes, num: func(p *Process) float64 { return parseByteString(p.Vms) }},
+	"swap":      {kind: queryBytes, num: func(p *Process) float64 { return parseByteString(p.Swap) }},
+	"pss":       {kind: queryBytes, num: func(p *Process) float64 { return memoryValue(p, func(m *MemoryDetail) uint64 { return m.Pss }) }},
+	"uss":       {kind: queryBytes, num: func(p *Process) float64 { return memoryValue(p, func(m *MemoryDetail) uint64 { return m.Uss }) }},
+	"read":      {kind: queryBytes, num: func(p *Process) float64 { return ioValue(p, func(c *IOCounters) uint64 { return c.ReadBytes }) }},
+	"write":     {kind: queryBytes, num: func(p *Process) float64 { return ioValue(p, func(c *IOCounters) uint64 { return c.WriteBytes }) }},
+	// 1秒あたりの読み書きの合計(Samplerで取得した時だけ値が入る)
+	"io": {kind: queryBytes, num: ioRate},
+}
+
+// 単位の倍率
+var byteUnits = map[string]float64{
+	"b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40, "tib": 1 << 40,
+	"p": 1 << 50, "pb": 1 << 50, "pib": 1 << 50,
+	"e": 1 << 60, "eb": 1 << 60, "eib": 1 << 60,
+}
+var durationUnits = map[string]float64{
+	"ms": 0.001, "s": 1, "m": 60, "h": 60 * 60, "d": 24 * 60 * 60,
+}
+
+// エラーで示す使える単位
+const (
+	byteUnitNames     = "B, KB, MB, GB, TB, PB, EB"
+	durationUnitNames = "ms, s, m, h, d"
+)
+
+// ParseQuery 式を解析する
+func ParseQuery(expr string) (*QueryExpr, error) {
+	tokens, err := lexQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	q := &QueryExpr{src: expr}
+	if len(tokens) == 0 {
+		return q, nil
+	}
+	ps := &queryParser{tokens: tokens}
+	q.root, err = ps.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if ps.pos < len(ps.tokens) {
+		return nil, fmt.Errorf("query: unexpected %q at %d", ps.tokens[ps.pos].text, ps.tokens[ps.pos].pos)
+	}
+	return q, nil
+}
+
+// String 解析前の式を返す
+func (q *QueryExpr) String() string {
+	return q.src
+}
+
+// Match プロセスが式に合うか判定する。空の式は全部合う
+func (q *QueryExpr) Match(p *Process) bool {
+	if q == nil || q.root == nil {
+		return true
+	}
+	return q.root.eval(p)
+}
+
+// Filter 式に合うプロセスだけを返す
+func (q *QueryExpr) Filter(ps Processes) Processes {
+	ret := Processes{}
+	for i := range ps {
+		if q.Match(&ps[i]) {
+			ret = append(ret, ps[i])
+		}
+	}
+	return ret
+}
+
+// Query 全プロセスから式に合うものをsortKeyの順に並べて、マシン全体の情報と一緒に返す
+// sortKeyは式と同じ項目名で、先頭に"-"を付けると降順。空ならPID順
+func Query(expr string, sortKey string) (*Listing, error) {
+	return query(nil, expr, sortKey)
+}
+
+// Query Queryと同じ。CPU使用率と1秒あたりのI/Oは前回呼び出した時からの値になる
+func (s *Sampler) Query(expr string, sortKey string) (*Listing, error) {
+	return query(s, expr, sortKey)
+}
+
+func query(s *Sampler, expr string, sortKey string) (*Listing, error) {
+	q, err := ParseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	// 一覧を取る前に並び順の指定も確認しておく
+	if err := SortProcesses(nil, sortKey); err != nil {
+		return nil, err
+	}
+	var l *Listing
+	if s == nil {
+		l, err = ListProcesses()
+	} else {
+		l, err = s.ListProcesses()
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.Processes = q.Filter(l.Processes)
+	SortProcesses(l.Processes, sortKey)
+	return l, nil
+}
+
+// SortProcesses 式と同じ項目名で並べ替える。先頭に"-"を付けると降順。空ならPID順
+func SortProcesses(ps Processes, key string) error {
+	desc := strings.HasPrefix(key, "-")
+	key = strings.TrimPrefix(key, "-")
+	if key == "" {
+		key = "pid"
+	}
+	f, ok := queryFields[strings.ToLower(key)]
+	if !ok {
+		return fmt.Errorf("query: unknown sort key %q", key)
+	}
+	less := func(a, b *Process) bool {
+		if f.kind == queryString {
+			return f.str(a) < f.str(b)
+		}
+		return f.num(a) < f.num(b)
+	}
+	sort.SliceStable(ps, func(i, j int) bool {
+		if desc {
+			return less(&ps[j], &ps[i])
+		}
+		return less(&ps[i], &ps[j])
+	})
+	return nil
+}
+
+// processUser 実効ユーザー名(取れなければ実ユーザー名)
+func processUser(p *Process) string {
+	if p.Owner == nil {
+		return ""
+	}
+	if p.Owner.EffectiveUser != "" {
+		return p.Owner.EffectiveUser
+	}
+	return p.Owner.User
+}
+
+// processGroup 実効グループ名(取れなければ実グループ名)
+func processGroup(p *Process) string {
+	if p.Owner == nil {
+		return ""
+	}
+	if p.Owner.EffectiveGroup != "" {
+		return p.Owner.EffectiveGroup
+	}
+	return p.Owner.Group
+}
+
+func cgroupString(p *Process, f func(c *CgroupInfo) string) string {
+	if p.Cgroup == nil {
+		return ""
+	}
+	return f(p.Cgroup)
+}
+
+func memoryValue(p *Process, f func(m *MemoryDetail) uint64) float64 {
+	if p.Memory == nil {
+		return 0
+	}
+	return float64(f(p.Memory))
+}
+
+func ioValue(p *Process, f func(c *IOCounters) uint64) float64 {
+	if p.IO == nil {
+		return 0
+	}
+	return float64(f(p.IO))
+}
+
+func ioRate(p *Process) float64 {
+	if p.IORates == nil {
+		return 0
+	}
+	return p.IORates.ReadBytes + p.IORates.WriteBytes
+}
+
+// parseByteString Rss等の"27.12MB"形式の文字列をバイト数に戻す。取得エラーの文字列は0
+// bytesize.Parse()は小数点を含む値を解析できないので自前で戻す
+func parseByteString(s string) float64 {
+	b, err := parseQueryNumber(s, queryBytes)
+	if err != nil {
+		return 0
+	}
+	return b
+}
+
+// 式の要素
+type queryNode interface {
+	eval(p *Process) bool
+}
+
+type queryAnd struct{ l, r queryNode }
+type queryOr struct{ l, r queryNode }
+type queryNot struct{ n queryNode }
+
+func (n queryAnd) eval(p *Process) bool { return n.l.eval(p) && n.r.eval(p) }
+func (n queryOr) eval(p *Process) bool  { return n.l.eval(p) || n.r.eval(p) }
+func (n queryNot) eval(p *Process) bool { return !n.n.eval(p) }
+
+// 項目と値の比較
+type queryCompare struct {
+	field queryField
+	op    string
+	str   string
+	num   float64
+	re    *regexp.Regexp
+}
+
+func (n queryCompare) eval(p *Process) bool {
+	if n.field.kind == queryString {
+		v := n.field.str(p)
+		switch n.op {
+		case "==":
+			return v == n.str
+		case "!=":
+			return v != n.str
+		case "=~":
+			return n.re.MatchString(v)
+		case "!~":
+			return !n.re.MatchString(v)
+		}
+		return false
+	}
+	v := n.field.num(p)
+	switch n.op {
+	case "==":
+		return v == n.num
+	case "!=":
+		return v != n.num
+	case ">":
+		return v > n.num
+	case ">=":
+		return v >= n.num
+	case "<":
+		return v < n.num
+	case "<=":
+		return v <= n.num
+	}
+	return false
+}
+
+// 字句
+type queryTokenKind int
+
+const (
+	tokenIdent queryTokenKind = iota
+	tokenString
+	tokenNumber
+	tokenOp
+)
+
+type queryToken struct {
+	kind queryTokenKind
+	text string
+	pos  int
+}
+
+// lexQuery 式を字句に分ける
+func lexQuery(expr string) ([]queryToken, error) {
+	tokens := []queryToken{}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(expr) && expr[end] != c {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("query: unterminated string at %d", i)
+			}
+			s := expr[i+1 : end]
+			if c == '"' {
+				var err error
+				if s, err = strconv.Unquote(expr[i : end+1]); err != nil {
+					return nil, fmt.Errorf("query: invalid string at %d: %w", i, err)
+				}
+			}
+			tokens = append(tokens, queryToken{tokenString, s, i})
+			i = end + 1
+		case c >= '0' && c <= '9' || c == '.' || c == '-' && i+1 < len(expr) && isQueryDigit(expr[i+1]):
+			// 数値の後ろに単位が続く。引き算は無いので'-'は負の数の符号(niceは負の値がある)
+			end := i + 1
+			for end < len(expr) && (isQueryDigit(expr[end]) || isQueryLetter(expr[end])) {
+				end++
+			}
+			tokens = append(tokens, queryToken{tokenNumber, expr[i:end], i})
+			i = end
+		case isQueryLetter(c):
+			end := i
+			for end < len(expr) && (isQueryLetter(expr[end]) || isQueryDigit(expr[end])) {
+				end++
+			}
+			tokens = append(tokens, queryToken{tokenIdent, expr[i:end], i})
+			i = end
+		default:
+			op := ""
+			for _, o := range []string{"&&", "||", "==", "!=", ">=", "<=", "=~", "!~", ">", "<", "!", "(", ")"} {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("query: unexpected %q at %d", c, i)
+			}
+			tokens = append(tokens, queryToken{tokenOp, op, i})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+func isQueryDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c == '.'
+}
+
+func isQueryLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// 再帰下降で解析する(優先順位は! > && > ||)
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (ps *queryParser) peek() *queryToken {
+	if ps.pos >= len(ps.tokens) {
+		return nil
+	}
+	return &ps.tokens[ps.pos]
+}
+
+func (ps *queryParser) acceptOp(op string) bool {
+	if t := ps.peek(); t != nil && t.kind == tokenOp && t.text == op {
+		ps.pos++
+		return true
+	}
+	return false
+}
+
+func (ps *queryParser) parseOr() (queryNode, error) {
+	l, err := ps.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for ps.acceptOp("||") {
+		r, err := ps.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = queryOr{l, r}
+	}
+	return l, nil
+}
+
+func (ps *queryParser) parseAnd() (queryNode, error) {
+	l, err := ps.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for ps.acceptOp("&&") {
+		r, err := ps.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = queryAnd{l, r}
+	}
+	return l, nil
+}
+
+func (ps *queryParser) parseUnary() (queryNode, error) {
+	if ps.acceptOp("!") {
+		n, err := ps.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return queryNot{n}, nil
+	}
+	if ps.acceptOp("(") {
+		n, err := ps.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !ps.acceptOp(")") {
+			return nil, ps.errorf("expected \")\"")
+		}
+		return n, nil
+	}
+	return ps.parseCompare()
+}
+
+func (ps *queryParser) parseCompare() (queryNode, error) {
+	t := ps.peek()
+	if t == nil || t.kind != tokenIdent {
+		return nil, ps.errorf("expected field name")
+	}
+	name := strings.ToLower(t.text)
+	f, ok := queryFields[name]
+	if !ok {
+		return nil, fmt.Errorf("query: unknown field %q at %d", t.text, t.pos)
+	}
+	ps.pos++
+
+	op := ps.peek()
+	if op == nil || op.kind != tokenOp {
+		return nil, ps.errorf("expected operator after %s", name)
+	}
+	ps.pos++
+	n := queryCompare{field: f, op: op.text}
+	switch op.text {
+	case "==", "!=":
+	case ">", ">=", "<", "<=":
+		if f.kind == queryString {
+			return nil, fmt.Errorf("query: %s is not a number at %d", name, op.pos)
+		}
+	case "=~", "!~":
+		if f.kind != queryString {
+			return nil, fmt.Errorf("query: %s is not a string at %d", name, op.pos)
+		}
+	default:
+		return nil, fmt.Errorf("query: unexpected %q at %d", op.text, op.pos)
+	}
+
+	v := ps.peek()
+	if v == nil || v.kind == tokenOp {
+		return nil, ps.errorf("expected value after %s %s", name, op.text)
+	}
+	ps.pos++
+	if f.kind == queryString {
+		// 文字列の項目は引用符の無い数値や単語もそのまま文字列として比べる
+		n.str = v.text
+		if n.op == "=~" || n.op == "!~" {
+			re, err := regexp.Compile(v.text)
+			if err != nil {
+				return nil, fmt.Errorf("query: invalid regexp at %d: %w", v.pos, err)
+			}
+			n.re = re
+		}
+		return n, nil
+	}
+	if v.kind != tokenNumber {
+		return nil, fmt.Errorf("query: %s needs a number at %d", name, v.pos)
+	}
+	num, err := parseQueryNumber(v.text, f.kind)
+	if err != nil {
+		return nil, fmt.Errorf("query: %s at %d: %w", name, v.pos, err)
+	}
+	n.num = num
+	return n, nil
+}
+
+func (ps *queryParser) errorf(format string, a ...interface{}) error {
+	pos := -1
+	if t := ps.peek(); t != nil {
+		pos = t.pos
+	}
+	if pos < 0 {
+		return fmt.Errorf("query: "+format+" at end", a...)
+	}
+	return fmt.Errorf("query: "+format+" at %d", append(a, pos)...)
+}
+
+// parseQueryNumber 単位付きの数値を項目の種類に合わせてバイト数か秒数にする
+func parseQueryNumber(text string, kind queryKind) (float64, error) {
+	end := 0
+	if strings.HasPrefix(text, "-") {
+		end++
+	}
+	for end < len(text) && isQueryDigit(text[end]) {
+		end++
+	}
+	num, err := strconv.ParseFloat(text[:end], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+	unit := strings.ToLower(text[end:])
+	if unit == "" {
+		return num, nil
+	}
+	var units map[string]float64
+	var names string
+	switch kind {
+	case queryBytes:
+		units, names = byteUnits, byteUnitNames
+	case queryDuration:
+		units, names = durationUnits, durationUnitNames
+	default:
+		return 0, fmt.Errorf("unit is not allowed: %q", text)
+	}
+	m, ok := units[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q in %q (use %s)", text[end:], text, names)
+	}
+	return num * m, nil
+}
@@ -0,0 +1,92 @@
+package goproc
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	java := Process{Name: "java", Pid: 100, CpuPercent: 80, Rss: "2.00GB", Uptime: 2 * time.Hour,
+		Owner: &Owner{User: "jetty", EffectiveUser: "jetty"}, NumThreads: 120}
+	worker := Process{Name: "worker", Pid: 200, CpuPercent: 10, Rss: "512.00MB", Uptime: 5 * time.Minute,
+		Owner: &Owner{User: "root", EffectiveUser: "root"}, NumThreads: 4, Nice: -5}
+	ps := Processes{java, worker}
+
+	cases := []struct {
+		in     string
+		except []int
+		msg    string
+	}{
+		{``, []int{100, 200}, "空の式は全部"},
+		{`name =~ "java" && rss > 1GB && cpu > 50`, []int{100}, "正規表現とサイズとCPU"},
+		{`rss >= 512MB`, []int{100, 200}, "サイズの境界"},
+		{`rss < 536870912`, []int{}, "単位無しはバイト"},
+		{`uptime < 10m || threads > 100`, []int{100, 200}, "時間と論理和"},
+		{`!(user == "root")`, []int{100}, "否定と括弧"},
+		{`user != jetty && pid == 200`, []int{200}, "引用符の無い文字列"},
+		{`uptime > 1h && name !~ '^w'`, []int{100}, "単一引用符"},
+		{`nice < -1`, []int{200}, "負の数"},
+		{`nice<-5||nice>-.5`, []int{100}, "空白無しの負の数と小数"},
+		{`rss < 1pb && rss < 1EiB`, []int{100, 200}, "ペタとエクサ"},
+	}
+	for _, c := range cases {
+		t.Run(c.msg, func(t *testing.T) {
+			q, err := ParseQuery(c.in)
+			if err != nil {
+				t.Fatalf("ParseQuery(%s) = %s, Failed", c.in, err)
+			}
+			got := q.Filter(ps)
+			if len(got) != len(c.except) {
+				t.Fatalf("Filter(%s) = %d, expect = %d, Failed", c.in, len(got), len(c.except))
+			}
+			for i := range got {
+				if got[i].Pid != c.except[i] {
+					t.Errorf("Filter(%s)[%d] = %d, expect = %d, Failed", c.in, i, got[i].Pid, c.except[i])
+				}
+			}
+		})
+	}
+
+	errs := []string{
+		`name > 1`,
+		`cpu =~ "1"`,
+		`cpu > 1GB`,
+		`rss > 1h`,
+		`foo == 1`,
+		`name == "java" &&`,
+		`(cpu > 1`,
+		`name =~ "("`,
+		`name == "java`,
+		`nice < -`,
+		`nice < - 5`,
+		`rss > 1zb`,
+	}
+	for _, in := range errs {
+		if _, err := ParseQuery(in); err == nil {
+			t.Errorf("ParseQuery(%s) = nil, Failed", in)
+		}
+	}
+
+	if err := SortProcesses(ps, "-rss"); err != nil || ps[0].Pid != 100 {
+		t.Errorf("SortProcesses(-rss) = %v, %d, Failed", err, ps[0].Pid)
+	}
+	if err := SortProcesses(ps, "uptime"); err != nil || ps[0].Pid != 200 {
+		t.Errorf("SortProcesses(uptime) = %v, %d, Failed", err, ps[0].Pid)
+	}
+	if err := SortProcesses(ps, "unknown"); err == nil {
+		t.Error("SortProcesses(unknown) = nil, Failed")
+	}
+
+	live, err := Query(fmt.Sprintf("pid == %d && threads > 0", os.Getpid()), "-cpu")
+	if err != nil {
+		t.Fatalf("Query(self) = %s, Failed", err)
+	}
+	if len(live.Processes) != 1 || live.Host == nil {
+		t.Errorf("Query(self) = %d, %v, Failed", len(live.Processes), live.Host)
+	}
+	if _, err := Query("", "unknown"); err == nil {
+		t.Error("Query(sort unknown) = nil, Failed")
+	}
+}
@@ -0,0 +1,35 @@
+package goproc
+
+import (
+	"sync"
+	"time"
+)
+
+// サブリーパーとして回収した孤児プロセスの情報
+type ReapedProcess struct {
+	Pid      int       `json:"pid"`
+	Name     string    `json:"name"`
+	ExitCode int       `json:"exitCode"`
+	Signal   string    `json:"signal"`
+	Time     time.Time `json:"time"`
+}
+
+// サブリーパーが回収してはいけない、cmd.Wait()で回収する子プロセス
+var managedChildren sync.Map
+
+// TrackChild cmd.Wait()で回収する子プロセスとしてサブリーパーの回収から除く
+// EnableSubreaperを使う時、利用側がos/execで起動した子プロセスはStart直後に登録する
+func TrackChild(pid int) {
+	managedChildren.Store(pid, struct{}{})
+}
+
+// UntrackChild cmd.Wait()が終わった子プロセスの登録を外す
+func UntrackChild(pid int) {
+	managedChildren.Delete(pid)
+}
+
+// isTrackedChild cmd.Wait()で回収する子プロセスか
+func isTrackedChild(pid int) bool {
+	_, ok := managedChildren.Load(pid)
+	return ok
+}
@@ -0,0 +1,157 @@
+package goproc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ReapGrace 引き取った子プロセスがゾンビになってから回収するまでの猶予
+// 孤児を見分けるために/procを確認する間隔にも使う
+var ReapGrace = 1 * time.Second
+
+// EnableSubreaper 自プロセスをサブリーパーにして、親が居なくなった子孫プロセスを引き取って回収する
+// 回収したプロセスはチャネルで知らせる。ctxが終わるとチャネルを閉じる(サブリーパーの設定はそのまま)
+// TrackChildで登録していない子プロセスはゾンビになってReapGraceが過ぎると回収するので、os/execの子プロセスは登録すること
+func EnableSubreaper(ctx context.Context) (<-chan ReapedProcess, error) {
+	if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0); err != nil {
+		return nil, fmt.Errorf("set child subreaper: %w", err)
+	}
+
+	ch := make(chan ReapedProcess, 64)
+	sigchld := make(chan os.Signal, 1)
+	signal.Notify(sigchld, syscall.SIGCHLD)
+
+	r := &orphanReaper{zombies: map[int]time.Time{}}
+
+	go func() {
+		defer close(ch)
+		defer signal.Stop(sigchld)
+
+		// SIGCHLDはまとめて届くことがあるので定期的にも確認する
+		ticker := time.NewTicker(ReapGrace)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigchld:
+			case <-ticker.C:
+			}
+			for _, rp := range r.reap() {
+				select {
+				case ch <- rp:
+				default:
+					log.Printf("error: reaped process channel is full, drop %d(%s)", rp.Pid, rp.Name)
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// 引き取った孤児プロセスを回収する
+type orphanReaper struct {
+	// 初めてゾンビを見つけた時刻
+	zombies map[int]time.Time
+}
+
+// /proc/<pid>/statから読んだ値
+type procStatEntry struct {
+	name  string
+	state string
+	ppid  int
+	start string
+}
+
+// reap ゾンビになっている子プロセスをReapGraceの猶予の後に回収する
+// TrackChildで登録した子プロセスはcmd.Wait()で回収するので除く
+func (r *orphanReaper) reap() []ReapedProcess {
+	ret := []ReapedProcess{}
+	self := os.Getpid()
+	now := time.Now()
+
+	procs := scanProcStat()
+	for pid, p := range procs {
+		if p.ppid != self || p.state != "Z" || isTrackedChild(pid) {
+			continue
+		}
+		first, ok := r.zombies[pid]
+		if !ok {
+			r.zombies[pid] = now
+			first = now
+		}
+		if now.Sub(first) < ReapGrace {
+			continue
+		}
+
+		name := readComm(pid)
+		var status syscall.WaitStatus
+		wpid, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil)
+		if err != nil || wpid != pid {
+			continue
+		}
+		delete(r.zombies, pid)
+
+		rp := ReapedProcess{Pid: pid, Name: name, ExitCode: status.ExitStatus(), Time: now}
+		if status.Signaled() {
+			rp.Signal = unix.SignalName(status.Signal())
+		}
+		ret = append(ret, rp)
+	}
+	// 居なくなったもの(他で回収されたもの)は忘れる
+	for pid := range r.zombies {
+		if _, ok := procs[pid]; !ok {
+			delete(r.zombies, pid)
+		}
+	}
+	return ret
+}
+
+// scanProcStat 全プロセスの名前、状態、親のPID、起動時刻を返す
+func scanProcStat() map[int]procStatEntry {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	ret := map[int]procStatEntry{}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			continue
+		}
+		// statの3番目がstate、4番目がppid、22番目がstarttime(procStatFieldsは3番目が先頭)
+		fields := procStatFields(string(b))
+		if len(fields) < 22-2 {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[4-3])
+		// コマンド名は括弧の中(括弧を含むことがあるので最後の")"まで)
+		stat := string(b)
+		name := stat[strings.Index(stat, "(")+1 : strings.LastIndex(stat, ")")]
+		ret[pid] = procStatEntry{name: name, state: fields[0], ppid: ppid, start: fields[22-3]}
+	}
+	return ret
+}
+
+// readComm /proc/<pid>/commからプロセス名を返す(ゾンビでも読める)
+func readComm(pid int) string {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,89 @@
+package goproc
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseSignal(t *testing.T) {
+	cases := []struct {
+		in     string
+		except syscall.Signal
+		msg    string
+	}{
+		{"SIGTERM", syscall.SIGTERM, "SIG付き"},
+		{"kill", syscall.SIGKILL, "SIG無しの小文字"},
+		{"9", syscall.SIGKILL, "数値"},
+	}
+	for _, c := range cases {
+		t.Run(c.msg, func(t *testing.T) {
+			sig, err := parseSignal(c.in)
+			if err != nil || sig != c.except {
+				t.Errorf("parseSignal = %v, %v, expect = %v, Failed", sig, err, c.except)
+			}
+		})
+	}
+	if _, err := parseSignal("SIGNOSUCH"); err == nil {
+		t.Errorf("parseSignal nothing err, Failed")
+	}
+
+	cmd := exec.Command("true")
+	if err := setPdeathsig(cmd, ProcessParam{Pdeathsig: "TERM"}); err != nil || cmd.SysProcAttr.Pdeathsig != syscall.SIGTERM {
+		t.Errorf("setPdeathsig = %v, Failed", err)
+	}
+}
+
+func TestEnableSubreaper(t *testing.T) {
+	grace := ReapGrace
+	ReapGrace = 100 * time.Millisecond
+	defer func() { ReapGrace = grace }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reaped, err := EnableSubreaper(ctx)
+	if err != nil {
+		t.Skipf("EnableSubreaper = %s", err)
+	}
+
+	// TrackChildで登録した子プロセスはcmd.Wait()が遅れても回収しない
+	own := exec.Command("true")
+	if err := own.Start(); err != nil {
+		t.Fatal(err)
+	}
+	TrackChild(own.Process.Pid)
+	defer UntrackChild(own.Process.Pid)
+
+	cases := []struct {
+		script string
+		msg    string
+	}{
+		{"sleep 0.6 & sleep 0.3", "中間のプロセスが後で終了する"},
+		{"sleep 0.3 &", "中間のプロセスがすぐ終了する"},
+	}
+	for _, c := range cases {
+		t.Run(c.msg, func(t *testing.T) {
+			// shが終了するとsleepは孤児になり、サブリーパーの自プロセスに引き取られる
+			if err := exec.Command("sh", "-c", c.script).Run(); err != nil {
+				t.Fatalf("sh = %s, Failed", err)
+			}
+			select {
+			case r := <-reaped:
+				if r.Name != "sleep" || r.ExitCode != 0 {
+					t.Errorf("reaped = %#v, Failed", r)
+				}
+			case <-time.After(5 * time.Second):
+				t.Errorf("orphan is not reaped, Failed")
+			}
+		})
+	}
+	if err := own.Wait(); err != nil {
+		t.Errorf("cmd.Wait = %s, Failed", err)
+	}
+
+	cancel()
+	for range reaped {
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"context"
+)
+
+// EnableSubreaper サブリーパーはLinuxのみ対応
+func EnableSubreaper(ctx context.Context) (<-chan ReapedProcess, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,140 @@
+package goproc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// Sampler 前回取得した値との差分から、ブロッキングせずにCPU使用率や1秒あたりのI/Oを計算する
+// 同じPIDでも起動時刻が違えば別のプロセスとして扱う。初回は差分が無いので0になる
+// ゼロ値のSampler{}もそのまま使える
+type Sampler struct {
+	// この時間より前に取得したまま更新されない値は捨てる(終了したプロセスの分)。0以下はdefaultSampleExpire
+	Expire time.Duration
+	// CPU使用率の表し方。空ならOSの既定(WinはCPUModeMachine、それ以外はCPUModePerCore)
+	CPUMode CPUMode
+
+	mu   sync.Mutex
+	prev map[sampleKey]sample
+	last time.Time
+}
+
+// 前回値を保存するキー
+type sampleKey struct {
+	pid        int
+	createTime int64
+	kind       string
+}
+
+// 前回値
+type sample struct {
+	time time.Time
+	cpu  float64
+	io   IOCounters
+}
+
+// Expireを指定しない時に前回値を捨てるまでの時間
+const defaultSampleExpire = 5 * time.Minute
+
+// NewSampler Samplerを作成する
+func NewSampler() *Sampler {
+	return &Sampler{
+		Expire: defaultSampleExpire,
+		prev:   map[sampleKey]sample{},
+	}
+}
+
+// GetProcess GetProcessと同じ情報を返す。CPU使用率と1秒あたりのI/Oは前回呼び出した時からの値になる
+func (s *Sampler) GetProcess(pid int) (*Process, error) {
+	return getProcess(pid, s, nil)
+}
+
+// GetProcesses GetProcessesと同じ情報を返す。CPU使用率と1秒あたりのI/Oは前回呼び出した時からの値になる
+func (s *Sampler) GetProcesses(pids []int) (Processes, error) {
+	ret := []Process{}
+	// 子孫の集計に使うプロセスツリーはまとめて1回だけ作る
+	tree, _ := processTree()
+	for _, pid := range pids {
+		p, err := getProcess(pid, s, tree)
+		if err != nil {
+			// errorならスキップする(全部エラーなら0個返す)
+			continue
+		}
+		ret = append(ret, *p)
+	}
+
+	return ret, nil
+}
+
+// cpuMode CPU使用率の表し方を返す。Samplerが無いか指定が無ければOSの既定
+func (s *Sampler) cpuMode() CPUMode {
+	if s == nil || s.CPUMode == "" {
+		return defaultCPUPercentMode
+	}
+	return s.CPUMode
+}
+
+// swap 今回の値を保存して前回の値を返す
+func (s *Sampler) swap(key sampleKey, cur sample) (sample, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.prev == nil {
+		s.prev = map[sampleKey]sample{}
+	}
+	expire := s.Expire
+	if expire <= 0 {
+		expire = defaultSampleExpire
+	}
+	// 古い値の掃除はExpireの間隔で行う
+	if cur.time.Sub(s.last) > expire {
+		for k, v := range s.prev {
+			if cur.time.Sub(v.time) > expire {
+				delete(s.prev, k)
+			}
+		}
+		s.last = cur.time
+	}
+
+	prev, ok := s.prev[key]
+	s.prev[key] = cur
+	return prev, ok
+}
+
+// cpuPercent CPU使用率を返す。Samplerが無ければ1秒ブロッキングして計測する
+func (s *Sampler) cpuPercent(p *process.Process) (float64, error) {
+	if s == nil {
+		return getCPUPercent(p)
+	}
+	times, err := p.Times()
+	if err != nil {
+		return 0, err
+	}
+	createtime, _ := p.CreateTime()
+	cur := sample{time: time.Now(), cpu: times.Total()}
+	prev, ok := s.swap(sampleKey{int(p.Pid), createtime, "cpu"}, cur)
+	if !ok {
+		return 0, nil
+	}
+	elapsed := cur.time.Sub(prev.time).Seconds()
+	if elapsed <= 0 || cur.cpu < prev.cpu {
+		return 0, nil
+	}
+	return normalizeCPUPercent(s.cpuMode(), (cur.cpu-prev.cpu)/elapsed*100), nil
+}
+
+// ioRates 1秒あたりのI/Oを返す。Samplerが無いか初回ならnil
+func (s *Sampler) ioRates(p *process.Process, kind string, io *IOCounters) *IORates {
+	if s == nil || io == nil {
+		return nil
+	}
+	createtime, _ := p.CreateTime()
+	cur := sample{time: time.Now(), io: *io}
+	prev, ok := s.swap(sampleKey{int(p.Pid), createtime, kind}, cur)
+	if !ok {
+		return nil
+	}
+	return ioRates(prev.io, cur.io, cur.time.Sub(prev.time).Seconds())
+}
@@ -0,0 +1,69 @@
+package goproc_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gozuk16/goproc"
+)
+
+func TestSampler(t *testing.T) {
+	s := goproc.NewSampler()
+
+	p, err := s.GetProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("GetProcess = %s, Failed", err)
+	}
+	if p.IORates != nil || p.CpuPercent != 0 {
+		t.Errorf("first sample = %#v, %v, Failed", p.IORates, p.CpuPercent)
+	}
+
+	// CPUを使ってI/Oも発生させる
+	end := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(end) {
+	}
+	f, err := os.CreateTemp(t.TempDir(), "sampler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(make([]byte, 4096))
+	f.Close()
+
+	p, err = s.GetProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("GetProcess = %s, Failed", err)
+	}
+	if p.IO == nil || p.IORates == nil || p.SumIO == nil || p.SumIORates == nil {
+		t.Fatalf("second sample = %#v, %#v, Failed", p.IO, p.IORates)
+	}
+	if p.CpuPercent <= 0 {
+		t.Errorf("CpuPercent = %v, Failed", p.CpuPercent)
+	}
+	if p.IORates.WriteCount <= 0 {
+		t.Errorf("IORates = %#v, Failed", p.IORates)
+	}
+
+	ps, err := s.GetProcesses([]int{0, os.Getpid()})
+	if err != nil || len(ps) != 1 {
+		t.Errorf("GetProcesses = %d, %v, Failed", len(ps), err)
+	}
+}
+
+func TestSamplerZeroValue(t *testing.T) {
+	var s goproc.Sampler
+	if _, err := s.GetProcess(os.Getpid()); err != nil {
+		t.Fatalf("GetProcess = %s, Failed", err)
+	}
+	end := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(end) {
+	}
+	// Expireが0でも前回値を捨てずに差分を計算する
+	p, err := s.GetProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("GetProcess = %s, Failed", err)
+	}
+	if p.CpuPercent <= 0 {
+		t.Errorf("CpuPercent = %v, Failed", p.CpuPercent)
+	}
+}
@@ -0,0 +1,242 @@
+package goproc
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ionice(1)と同じI/Oスケジューリングクラス名
+var ioClasses = map[string]int{
+	"realtime":    1,
+	"best-effort": 2,
+	"idle":        3,
+}
+
+// /proc/<pid>/statのpolicyの値とスケジューリングポリシー名
+var schedPolicies = map[int]string{
+	0: "other",
+	1: "fifo",
+	2: "rr",
+	3: "batch",
+	5: "idle",
+	6: "deadline",
+}
+
+const ioprioWhoProcess = 1
+
+// setThreadSchedule 呼び出し元のスレッドにnice、I/O優先度、CPUアフィニティを設定する
+// Linuxではどれもスレッド単位の値でforkした子に引き継がれるので、LockOSThreadしたスレッドで起動前に設定すると
+// サービスが起動直後に作るスレッドや子プロセスも含めて最初からこの値で動く
+func setThreadSchedule(param ProcessParam) error {
+	tid := unix.Gettid()
+	if param.Nice != nil {
+		if err := checkNice(*param.Nice); err != nil {
+			return err
+		}
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, *param.Nice); err != nil {
+			return fmt.Errorf("set nice %d: %w", *param.Nice, err)
+		}
+	}
+	if param.IOClass != "" {
+		prio, err := ioprioValue(param.IOClass, param.IOPriority)
+		if err != nil {
+			return err
+		}
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio)); errno != 0 {
+			return fmt.Errorf("set io priority %s/%d: %w", param.IOClass, param.IOPriority, errno)
+		}
+	}
+	if len(param.CPUAffinity) > 0 {
+		set, err := cpuSet(param.CPUAffinity)
+		if err != nil {
+			return err
+		}
+		if err := unix.SchedSetaffinity(tid, set); err != nil {
+			return fmt.Errorf("set affinity %v: %w", param.CPUAffinity, err)
+		}
+	}
+	return nil
+}
+
+// taskIds 指定されたPIDの全スレッドのIDを返す。Linuxのnice、ionice、アフィニティはスレッド単位なので全スレッドに適用する
+func taskIds(pid int) ([]int, error) {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+	if err != nil {
+		return nil, err
+	}
+	tids := []int{}
+	for _, e := range entries {
+		tid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		tids = append(tids, tid)
+	}
+	return tids, nil
+}
+
+// checkNice nice値の範囲を確認する
+func checkNice(n int) error {
+	if n < -20 || n > 19 {
+		return fmt.Errorf("nice must be between -20 and 19: %d", n)
+	}
+	return nil
+}
+
+// ioprioValue I/Oスケジューリングクラスと優先度からioprio_setに渡す値を作る
+func ioprioValue(class string, priority int) (int, error) {
+	c, ok := ioClasses[class]
+	if !ok {
+		return 0, fmt.Errorf("unknown io scheduling class: %s", class)
+	}
+	if priority < 0 || priority > 7 {
+		return 0, fmt.Errorf("io priority must be between 0 and 7: %d", priority)
+	}
+	// idleクラスに優先度は無い
+	if class == "idle" {
+		priority = 0
+	}
+	return c<<13 | priority, nil
+}
+
+// cpuSet CPU番号のリストからCPUSetを作る
+func cpuSet(cpus []int) (*unix.CPUSet, error) {
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("cpus is empty")
+	}
+	var set unix.CPUSet
+	for _, c := range cpus {
+		if c < 0 || c >= len(set)*64 {
+			return nil, fmt.Errorf("invalid cpu number: %d", c)
+		}
+		set.Set(c)
+	}
+	return &set, nil
+}
+
+// SetPriority 指定されたPIDの全スレッドのnice値(-20から19)を変更する
+func SetPriority(pid int, n int) error {
+	if err := checkNice(n); err != nil {
+		return err
+	}
+	tids, err := taskIds(pid)
+	if err != nil {
+		return err
+	}
+	for _, tid := range tids {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, n); err != nil {
+			return fmt.Errorf("set nice %d to %d: %w", n, tid, err)
+		}
+	}
+	return nil
+}
+
+// SetIOPriority 指定されたPIDの全スレッドのI/Oスケジューリングクラス(realtime, best-effort, idle)と優先度(0から7)を変更する
+func SetIOPriority(pid int, class string, priority int) error {
+	prio, err := ioprioValue(class, priority)
+	if err != nil {
+		return err
+	}
+	tids, err := taskIds(pid)
+	if err != nil {
+		return err
+	}
+	for _, tid := range tids {
+		_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio))
+		if errno != 0 {
+			return fmt.Errorf("set io priority %s/%d to %d: %w", class, priority, tid, errno)
+		}
+	}
+	return nil
+}
+
+// SetAffinity 指定されたPIDの全スレッドを動かすCPUを変更する
+func SetAffinity(pid int, cpus []int) error {
+	set, err := cpuSet(cpus)
+	if err != nil {
+		return err
+	}
+	tids, err := taskIds(pid)
+	if err != nil {
+		return err
+	}
+	for _, tid := range tids {
+		if err := unix.SchedSetaffinity(tid, set); err != nil {
+			return fmt.Errorf("set affinity %v to %d: %w", cpus, tid, err)
+		}
+	}
+	return nil
+}
+
+// getSchedule 指定されたPIDのnice値、スケジューリングポリシー、動作できるCPUを返す
+func getSchedule(pid int) (int, string, []int, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, "", nil, err
+	}
+	fields := procStatFields(string(b))
+	// statの19番目がnice、41番目がpolicy(procStatFieldsは3番目のstateが先頭)
+	if len(fields) < 39 {
+		return 0, "", nil, fmt.Errorf("invalid /proc/%d/stat", pid)
+	}
+	nice, _ := strconv.Atoi(fields[19-3])
+	policy, _ := strconv.Atoi(fields[41-3])
+	name, ok := schedPolicies[policy]
+	if !ok {
+		name = strconv.Itoa(policy)
+	}
+
+	status, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nice, name, nil, err
+	}
+	var cpus []int
+	for _, line := range strings.Split(string(status), "\n") {
+		if v := strings.TrimPrefix(line, "Cpus_allowed_list:"); v != line {
+			cpus, err = parseCPUList(strings.TrimSpace(v))
+			if err != nil {
+				return nice, name, nil, err
+			}
+			break
+		}
+	}
+	return nice, name, cpus, nil
+}
+
+// procStatFields /proc/<pid>/statをコマンド名の後から分割する(コマンド名にスペースや括弧が入っていることがある)
+func procStatFields(stat string) []string {
+	i := strings.LastIndex(stat, ")")
+	if i < 0 {
+		return nil
+	}
+	return strings.Fields(stat[i+1:])
+}
+
+// parseCPUList "0-3,8,10-11"形式のCPUリストをパースする
+func parseCPUList(s string) ([]int, error) {
+	cpus := []int{}
+	if s == "" {
+		return cpus, nil
+	}
+	for _, r := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(r, "-")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list: %s", s)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(to); err != nil || end < start {
+				return nil, fmt.Errorf("invalid cpu list: %s", s)
+			}
+		}
+		for c := start; c <= end; c++ {
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus, nil
+}
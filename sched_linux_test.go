@@ -0,0 +1,118 @@
+package goproc
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	cases := []struct {
+		in     string
+		except []int
+		msg    string
+	}{
+		{"0", []int{0}, "1つ"},
+		{"0-3", []int{0, 1, 2, 3}, "範囲"},
+		{"0-1,4,6-7", []int{0, 1, 4, 6, 7}, "範囲と単独の組み合わせ"},
+		{"", []int{}, "空"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.msg, func(t *testing.T) {
+			cpus, err := parseCPUList(c.in)
+			if err != nil {
+				t.Fatalf("parseCPUList = %s, Failed", err)
+			}
+			if !reflect.DeepEqual(cpus, c.except) {
+				t.Errorf("parseCPUList = %v, expect = %v, Failed", cpus, c.except)
+			}
+		})
+	}
+
+	for _, in := range []string{"a", "3-1", "1-"} {
+		if _, err := parseCPUList(in); err == nil {
+			t.Errorf("parseCPUList(%s) nothing err, Failed", in)
+		}
+	}
+}
+
+func TestProcStatFields(t *testing.T) {
+	fields := procStatFields("1234 (java (main) x) S 1 1234 1234 0 -1")
+	if len(fields) != 6 || fields[0] != "S" || fields[1] != "1" {
+		t.Errorf("procStatFields = %v, Failed", fields)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	cmd := startSleep(t, "5")
+	pid := cmd.Process.Pid
+
+	// 動いているプロセスの全スレッドを変更する
+	if err := SetPriority(pid, 10); err != nil {
+		t.Fatalf("SetPriority = %s, Failed", err)
+	}
+	if err := SetIOPriority(pid, "idle", 0); err != nil {
+		t.Fatalf("SetIOPriority = %s, Failed", err)
+	}
+	if err := SetAffinity(pid, []int{0}); err != nil {
+		t.Fatalf("SetAffinity = %s, Failed", err)
+	}
+	n, policy, cpus, err := getSchedule(pid)
+	if err != nil {
+		t.Fatalf("getSchedule = %s, Failed", err)
+	}
+	if n != 10 || policy != "other" || !reflect.DeepEqual(cpus, []int{0}) {
+		t.Errorf("getSchedule = %d, %s, %v, Failed", n, policy, cpus)
+	}
+
+	if err := SetPriority(pid, 20); err == nil {
+		t.Errorf("SetPriority nothing err, Failed")
+	}
+	if err := SetIOPriority(pid, "normal", 0); err == nil {
+		t.Errorf("SetIOPriority nothing err, Failed")
+	}
+	if err := SetAffinity(pid, []int{-1}); err == nil {
+		t.Errorf("SetAffinity nothing err, Failed")
+	}
+}
+
+func TestStartCmdSchedule(t *testing.T) {
+	before, _, _, err := getSchedule(os.Getpid())
+	if err != nil {
+		t.Fatalf("getSchedule = %s, Failed", err)
+	}
+
+	// 起動するスレッドに設定した値がプログラムの最初から引き継がれていること
+	cmd := exec.Command("sleep", "5")
+	nice := 10
+	param := ProcessParam{Nice: &nice, IOClass: "idle", CPUAffinity: []int{0}}
+	release, err := startCmd(cmd, func() error { return setThreadSchedule(param) })
+	if err != nil {
+		t.Fatalf("startCmd = %s, Failed", err)
+	}
+	pid := cmd.Process.Pid
+	n, policy, cpus, err := getSchedule(pid)
+	cmd.Process.Kill()
+	cmd.Wait()
+	release()
+	if err != nil {
+		t.Fatalf("getSchedule = %s, Failed", err)
+	}
+	if n != 10 || policy != "other" || !reflect.DeepEqual(cpus, []int{0}) {
+		t.Errorf("getSchedule = %d, %s, %v, Failed", n, policy, cpus)
+	}
+
+	// 起動元のプロセスは変わらない
+	if after, _, _, _ := getSchedule(os.Getpid()); after != before {
+		t.Errorf("nice of self = %d, expect = %d, Failed", after, before)
+	}
+
+	// 設定できなければ起動しない
+	nice = 20
+	cmd = exec.Command("sleep", "5")
+	if _, err := startCmd(cmd, func() error { return setThreadSchedule(param) }); err == nil || cmd.Process != nil {
+		t.Errorf("startCmd = %v, Failed", err)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"fmt"
+)
+
+// setThreadSchedule nice、I/O優先度、CPUアフィニティの適用はLinuxのみ対応
+func setThreadSchedule(param ProcessParam) error {
+	return fmt.Errorf("%w: nice, ionice or cpu affinity", ErrNotSupported)
+}
+
+// SetPriority nice値の変更はLinuxのみ対応
+func SetPriority(pid int, n int) error {
+	return ErrNotSupported
+}
+
+// SetIOPriority I/O優先度の変更はLinuxのみ対応
+func SetIOPriority(pid int, class string, priority int) error {
+	return ErrNotSupported
+}
+
+// SetAffinity CPUアフィニティの変更はLinuxのみ対応
+func SetAffinity(pid int, cpus []int) error {
+	return ErrNotSupported
+}
+
+// getSchedule スケジューリング情報の取得はLinuxのみ対応
+func getSchedule(pid int) (int, string, []int, error) {
+	return 0, "", nil, ErrNotSupported
+}
@@ -0,0 +1,166 @@
+package goproc
+
+import (
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// RssJumpBytes、RssJumpRatio Diffで大きなRSSの変化とみなす量と割合(両方を超えたら変化とする)
+var RssJumpBytes uint64 = 64 * 1024 * 1024
+var RssJumpRatio = 0.5
+
+// Diffで検出する変化の種類
+const (
+	ChangeParent = "parent"
+	ChangeExe    = "exe"
+	ChangeRss    = "rss"
+)
+
+// 全プロセスのスナップショット。PIDと起動時刻の組でプロセスを識別する
+type ProcessSnapshot struct {
+	Time      time.Time         `json:"time"`
+	Processes []SnapshotProcess `json:"processes"`
+}
+
+// スナップショット内のプロセス。RSSはバイト数
+type SnapshotProcess struct {
+	Pid       int       `json:"pid"`
+	Ppid      int       `json:"ppid"`
+	Name      string    `json:"name"`
+	Exe       string    `json:"exe"`
+	Cmdline   string    `json:"cmdline"`
+	Rss       uint64    `json:"rss"`
+	StartTime time.Time `json:"startTime"`
+}
+
+// 変化したプロセス
+type ProcessChange struct {
+	Prev    SnapshotProcess `json:"prev"`
+	Next    SnapshotProcess `json:"next"`
+	Changes []string        `json:"changes"`
+}
+
+// 2つのスナップショットの差分
+type SnapshotDiff struct {
+	Started []SnapshotProcess `json:"started"`
+	Exited  []SnapshotProcess `json:"exited"`
+	Changed []ProcessChange   `json:"changed"`
+}
+
+// PIDは再利用されるので起動時刻と組にする
+type snapshotKey struct {
+	pid       int
+	startTime int64
+}
+
+func (p *SnapshotProcess) key() snapshotKey {
+	return snapshotKey{p.Pid, p.StartTime.UnixNano()}
+}
+
+// Snapshot 全プロセスのスナップショットを取る。CPU使用率は計測しないのでブロッキングしない
+func Snapshot() (*ProcessSnapshot, error) {
+	pids, err := process.Pids()
+	if err != nil {
+		return nil, err
+	}
+	ret := &ProcessSnapshot{Time: time.Now(), Processes: []SnapshotProcess{}}
+	for _, pid := range pids {
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			continue
+		}
+		// 起動時刻が取れないと識別できないのでスキップする(終了したプロセス等)
+		createtime, err := p.CreateTime()
+		if err != nil {
+			continue
+		}
+		sp := SnapshotProcess{
+			Pid:       int(pid),
+			StartTime: time.Unix(createtime/1000, (createtime%1000)*int64(time.Millisecond)),
+		}
+		ppid, _ := p.Ppid()
+		sp.Ppid = int(ppid)
+		sp.Name, _ = p.Name()
+		sp.Exe, _ = p.Exe()
+		sp.Cmdline, _ = p.Cmdline()
+		if memory, err := p.MemoryInfo(); err == nil {
+			sp.Rss = memory.RSS
+		}
+		ret.Processes = append(ret.Processes, sp)
+	}
+	return ret, nil
+}
+
+// Find PIDでプロセスを探す
+func (s *ProcessSnapshot) Find(pid int) (*SnapshotProcess, bool) {
+	for i := range s.Processes {
+		if s.Processes[i].Pid == pid {
+			return &s.Processes[i], true
+		}
+	}
+	return nil, false
+}
+
+// Descendants 指定されたPIDの子孫のPIDをスナップショットから返す
+func (s *ProcessSnapshot) Descendants(pid int) []int {
+	tree := map[int][]int{}
+	for _, p := range s.Processes {
+		tree[p.Ppid] = append(tree[p.Ppid], p.Pid)
+	}
+	return descendants(pid, tree)
+}
+
+// index 識別子からプロセスを引く索引を作る
+// スナップショットに持たせると複数のgoroutineから呼ばれた時に競合するので、Diffの呼び出しごとに作る
+func (s *ProcessSnapshot) index() map[snapshotKey]*SnapshotProcess {
+	ret := make(map[snapshotKey]*SnapshotProcess, len(s.Processes))
+	for i := range s.Processes {
+		ret[s.Processes[i].key()] = &s.Processes[i]
+	}
+	return ret
+}
+
+// Diff 2つのスナップショットを比べて起動、終了、変化したプロセスを返す
+// 変化は親プロセス、実行ファイル(exec)、大きなRSSの増減(RssJumpBytesとRssJumpRatio)
+func Diff(prev, next *ProcessSnapshot) *SnapshotDiff {
+	ret := &SnapshotDiff{Started: []SnapshotProcess{}, Exited: []SnapshotProcess{}, Changed: []ProcessChange{}}
+	prevIndex, nextIndex := prev.index(), next.index()
+	for i := range next.Processes {
+		n := next.Processes[i]
+		p, ok := prevIndex[n.key()]
+		if !ok {
+			ret.Started = append(ret.Started, n)
+			continue
+		}
+		if changes := compareSnapshotProcess(p, &n); len(changes) > 0 {
+			ret.Changed = append(ret.Changed, ProcessChange{Prev: *p, Next: n, Changes: changes})
+		}
+	}
+	for i := range prev.Processes {
+		if _, ok := nextIndex[prev.Processes[i].key()]; !ok {
+			ret.Exited = append(ret.Exited, prev.Processes[i])
+		}
+	}
+	return ret
+}
+
+// compareSnapshotProcess 同じプロセスの変化を返す
+func compareSnapshotProcess(prev, next *SnapshotProcess) []string {
+	ret := []string{}
+	if prev.Ppid != next.Ppid {
+		ret = append(ret, ChangeParent)
+	}
+	// 権限が無いと実行ファイルは取れないので、両方取れた時だけ比べる
+	if prev.Exe != "" && next.Exe != "" && prev.Exe != next.Exe {
+		ret = append(ret, ChangeExe)
+	}
+	delta := next.Rss - prev.Rss
+	if next.Rss < prev.Rss {
+		delta = prev.Rss - next.Rss
+	}
+	if delta >= RssJumpBytes && float64(delta) >= float64(prev.Rss)*RssJumpRatio {
+		ret = append(ret, ChangeRss)
+	}
+	return ret
+}
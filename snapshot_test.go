@@ -0,0 +1,81 @@
+package goproc
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	jetty := SnapshotProcess{Pid: 100, Ppid: 1, Name: "java", Exe: "/usr/bin/java", Rss: 512 << 20, StartTime: start}
+	shell := SnapshotProcess{Pid: 200, Ppid: 100, Name: "sh", Exe: "/bin/sh", Rss: 1 << 20, StartTime: start}
+	old := SnapshotProcess{Pid: 300, Ppid: 1, Name: "old", Rss: 1 << 20, StartTime: start}
+	prev := &ProcessSnapshot{Processes: []SnapshotProcess{jetty, shell, old}}
+
+	grown := jetty
+	grown.Rss = 1 << 30
+	execed := shell
+	execed.Exe, execed.Name = "/usr/bin/curl", "curl"
+	// 同じPIDでも起動時刻が違えば別プロセス
+	reused := old
+	reused.StartTime = start.Add(time.Hour)
+	child := SnapshotProcess{Pid: 400, Ppid: 200, Name: "curl", StartTime: start.Add(time.Hour)}
+	next := &ProcessSnapshot{Processes: []SnapshotProcess{grown, execed, reused, child}}
+
+	d := Diff(prev, next)
+	if len(d.Started) != 2 || d.Started[0].Pid != 300 || d.Started[1].Pid != 400 {
+		t.Errorf("Started = %#v, Failed", d.Started)
+	}
+	if len(d.Exited) != 1 || d.Exited[0].Pid != 300 {
+		t.Errorf("Exited = %#v, Failed", d.Exited)
+	}
+	if len(d.Changed) != 2 {
+		t.Fatalf("Changed = %#v, Failed", d.Changed)
+	}
+	if d.Changed[0].Next.Pid != 100 || len(d.Changed[0].Changes) != 1 || d.Changed[0].Changes[0] != ChangeRss {
+		t.Errorf("Changed[0] = %#v, Failed", d.Changed[0])
+	}
+	if d.Changed[1].Next.Pid != 200 || len(d.Changed[1].Changes) != 1 || d.Changed[1].Changes[0] != ChangeExe {
+		t.Errorf("Changed[1] = %#v, Failed", d.Changed[1])
+	}
+	if desc := next.Descendants(100); len(desc) != 2 {
+		t.Errorf("Descendants = %v, Failed", desc)
+	}
+
+	// JSONで保存したスナップショットとも比べられる
+	b, _ := json.Marshal(prev)
+	restored := &ProcessSnapshot{}
+	if err := json.Unmarshal(b, restored); err != nil {
+		t.Fatal(err)
+	}
+	if d := Diff(restored, prev); len(d.Started)+len(d.Exited)+len(d.Changed) != 0 {
+		t.Errorf("Diff(restored) = %#v, Failed", d)
+	}
+
+	// 同じスナップショットを複数のgoroutineから比べても競合しない(go test -race)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if d := Diff(restored, next); len(d.Changed) != 2 {
+				t.Errorf("Diff(concurrent) = %#v, Failed", d)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSnapshot(t *testing.T) {
+	s, err := Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot = %s, Failed", err)
+	}
+	p, ok := s.Find(os.Getpid())
+	if !ok || p.Ppid != os.Getppid() || p.Rss == 0 || p.StartTime.IsZero() {
+		t.Errorf("Snapshot self = %#v, Failed", p)
+	}
+}
@@ -0,0 +1,14 @@
+package goproc
+
+// StatusUnsupported 状態が正しく取れないプラットフォームでStatusに入れる値
+const StatusUnsupported = "unsupported"
+
+// スケジューリングの統計(Linuxのみ。他のプラットフォームではnil)
+type SchedStats struct {
+	VoluntaryCtxSwitches   uint64 `json:"voluntaryCtxSwitches"`
+	InvoluntaryCtxSwitches uint64 `json:"involuntaryCtxSwitches"`
+	MinorFaults            uint64 `json:"minorFaults"`
+	MajorFaults            uint64 `json:"majorFaults"`
+	// 待っているカーネル関数。実行中や取れない場合は空
+	Wchan string `json:"wchan"`
+}
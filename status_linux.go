@@ -0,0 +1,74 @@
+package goproc
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// /proc/<pid>/statのstateの値
+var procStates = map[string]string{
+	"R": "running",
+	"S": "sleeping",
+	"D": "disk-sleep",
+	"Z": "zombie",
+	"T": "stopped",
+	"t": "tracing-stop",
+	"X": "dead",
+	"I": "idle",
+	"P": "parked",
+	"W": "waking",
+}
+
+// getStatus /proc/<pid>/stat、status、wchanから状態とスケジューリングの統計を取得する
+func getStatus(p *process.Process) (string, *SchedStats, error) {
+	pid := int(p.Pid)
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", nil, err
+	}
+	// statの3番目がstate、10番目がminflt、12番目がmajflt(procStatFieldsは3番目が先頭)
+	fields := procStatFields(string(b))
+	if len(fields) < 10 {
+		return "", nil, fmt.Errorf("invalid /proc/%d/stat", pid)
+	}
+	status := procStates[fields[0]]
+	if status == "" {
+		status = fields[0]
+	}
+	stats := &SchedStats{}
+	stats.MinorFaults, _ = strconv.ParseUint(fields[10-3], 10, 64)
+	stats.MajorFaults, _ = strconv.ParseUint(fields[12-3], 10, 64)
+
+	if b, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid)); err == nil {
+		stats.VoluntaryCtxSwitches, stats.InvoluntaryCtxSwitches = parseCtxSwitches(string(b))
+	}
+	// wchanは権限が無いと読めないので空のままにする
+	if b, err := os.ReadFile(fmt.Sprintf("/proc/%d/wchan", pid)); err == nil {
+		if w := strings.TrimSpace(string(b)); w != "0" {
+			stats.Wchan = w
+		}
+	}
+	return status, stats, nil
+}
+
+// parseCtxSwitches /proc/<pid>/statusからコンテキストスイッチの回数を取り出す
+func parseCtxSwitches(status string) (uint64, uint64) {
+	var voluntary, involuntary uint64
+	for _, line := range strings.Split(status, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "voluntary_ctxt_switches":
+			voluntary, _ = strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		case "nonvoluntary_ctxt_switches":
+			involuntary, _ = strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		}
+	}
+	return voluntary, involuntary
+}
@@ -0,0 +1,52 @@
+package goproc
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+func TestParseCtxSwitches(t *testing.T) {
+	v, iv := parseCtxSwitches("Name:\tjava\nvoluntary_ctxt_switches:\t150\nnonvoluntary_ctxt_switches:\t7\n")
+	if v != 150 || iv != 7 {
+		t.Errorf("parseCtxSwitches = %d, %d, Failed", v, iv)
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	cmd := startSleep(t, "5")
+	p, err := process.NewProcess(int32(cmd.Process.Pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 状態が変わるまで少し待つ
+	waitStatus := func(except string) (string, *SchedStats) {
+		var status string
+		var stats *SchedStats
+		for i := 0; i < 50; i++ {
+			status, stats, err = getStatus(p)
+			if err != nil {
+				t.Fatalf("getStatus = %s, Failed", err)
+			}
+			if status == except {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return status, stats
+	}
+
+	status, stats := waitStatus("sleeping")
+	if status != "sleeping" || stats == nil || stats.MinorFaults == 0 {
+		t.Errorf("getStatus = %s, %#v, Failed", status, stats)
+	}
+
+	cmd.Process.Signal(syscall.SIGSTOP)
+	if status, _ := waitStatus("stopped"); status != "stopped" {
+		t.Errorf("getStatus = %s, expect = stopped, Failed", status)
+	}
+	cmd.Process.Signal(syscall.SIGCONT)
+}
@@ -0,0 +1,73 @@
+package goproc
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// スレッド情報
+type Thread struct {
+	// スレッドID(Javaならスレッドダンプのnidが16進で表したこの値なので突き合わせられる)
+	Tid int `json:"tid"`
+	// スレッド名(comm。15文字までなのでJavaのスレッド名は途中で切れることがある)
+	Name       string  `json:"name"`
+	State      string  `json:"state"`
+	CpuPercent float64 `json:"cpuPercent"`
+	CpuUser    float64 `json:"cpuUser"`
+	CpuSystem  float64 `json:"cpuSystem"`
+}
+
+// 計測用のスレッドの値
+type threadStat struct {
+	tid    int
+	name   string
+	state  string
+	user   float64
+	system float64
+	// スレッドの起動時刻(同じTIDの再利用を見分ける)
+	start int64
+}
+
+// GetThreads 指定されたPIDのスレッド一覧を返す。CPU使用率は1秒ブロッキングして計測する
+func GetThreads(pid int) ([]Thread, error) {
+	// 渡されたpidがマイナス、0、1の時はエラーで返す(そうじゃないとPanicになる)
+	if pid <= 1 {
+		return nil, fmt.Errorf("Don't get process, when pid is %d", pid)
+	}
+	s := NewSampler()
+	if _, err := s.GetThreads(pid); err != nil {
+		return nil, err
+	}
+	time.Sleep(1 * time.Second)
+	return s.GetThreads(pid)
+}
+
+// GetThreads 指定されたPIDのスレッド一覧を返す。CPU使用率は前回呼び出した時からの値になる
+func (s *Sampler) GetThreads(pid int) ([]Thread, error) {
+	if pid <= 1 {
+		return nil, fmt.Errorf("Don't get process, when pid is %d", pid)
+	}
+	stats, err := readThreads(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ret := []Thread{}
+	for _, st := range stats {
+		t := Thread{Tid: st.tid, Name: st.name, State: st.state, CpuUser: st.user, CpuSystem: st.system}
+		cur := sample{time: now, cpu: st.user + st.system}
+		if prev, ok := s.swap(sampleKey{st.tid, st.start, "thread"}, cur); ok {
+			elapsed := cur.time.Sub(prev.time).Seconds()
+			if elapsed > 0 && cur.cpu >= prev.cpu {
+				t.CpuPercent = normalizeCPUPercent(s.cpuMode(), (cur.cpu-prev.cpu)/elapsed*100)
+			}
+		}
+		ret = append(ret, t)
+	}
+	// CPUを使っているスレッドを先頭にする
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].CpuPercent > ret[j].CpuPercent })
+
+	return ret, nil
+}
@@ -0,0 +1,64 @@
+package goproc
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// readThreads /proc/<pid>/task/<tid>/statから全スレッドの値を読む
+func readThreads(pid int) ([]threadStat, error) {
+	tids, err := taskIds(pid)
+	if err != nil {
+		return nil, err
+	}
+	ret := []threadStat{}
+	for _, tid := range tids {
+		b, err := os.ReadFile(fmt.Sprintf("/proc/%d/task/%d/stat", pid, tid))
+		if err != nil {
+			// 一覧を取ってから終了したスレッドはスキップする
+			continue
+		}
+		st, err := parseTaskStat(string(b))
+		if err != nil {
+			continue
+		}
+		ret = append(ret, st)
+	}
+	return ret, nil
+}
+
+// parseTaskStat /proc/<pid>/task/<tid>/statをパースする
+func parseTaskStat(stat string) (threadStat, error) {
+	st := threadStat{}
+	lp := strings.Index(stat, "(")
+	rp := strings.LastIndex(stat, ")")
+	if lp < 0 || rp < lp {
+		return st, fmt.Errorf("invalid stat: %s", stat)
+	}
+	tid, err := strconv.Atoi(strings.TrimSpace(stat[:lp]))
+	if err != nil {
+		return st, err
+	}
+	st.tid = tid
+	st.name = stat[lp+1 : rp]
+
+	// statの3番目がstate、14番目がutime、15番目がstime、22番目がstarttime(procStatFieldsは3番目が先頭)
+	fields := procStatFields(stat)
+	if len(fields) < 20 {
+		return st, fmt.Errorf("invalid stat: %s", stat)
+	}
+	st.state = procStates[fields[0]]
+	if st.state == "" {
+		st.state = fields[0]
+	}
+	utime, _ := strconv.ParseFloat(fields[14-3], 64)
+	stime, _ := strconv.ParseFloat(fields[15-3], 64)
+	st.user = utime / cpu.ClocksPerSec
+	st.system = stime / cpu.ClocksPerSec
+	st.start, _ = strconv.ParseInt(fields[22-3], 10, 64)
+	return st, nil
+}
@@ -0,0 +1,48 @@
+package goproc
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseTaskStat(t *testing.T) {
+	st, err := parseTaskStat("4321 (GC Thread#0) R 1234 1234 1234 0 -1 4194368 100 0 0 0 250 50 0 0 20 0 30 0 12345 0 0")
+	if err != nil {
+		t.Fatalf("parseTaskStat = %s, Failed", err)
+	}
+	if st.tid != 4321 || st.name != "GC Thread#0" || st.state != "running" || st.start != 12345 {
+		t.Errorf("parseTaskStat = %#v, Failed", st)
+	}
+	if st.user <= st.system || st.system <= 0 {
+		t.Errorf("parseTaskStat cpu = %v, %v, Failed", st.user, st.system)
+	}
+
+	if _, err := parseTaskStat("4321 GC"); err == nil {
+		t.Errorf("parseTaskStat nothing err, Failed")
+	}
+}
+
+func TestSamplerGetThreads(t *testing.T) {
+	s := NewSampler()
+	threads, err := s.GetThreads(os.Getpid())
+	if err != nil {
+		t.Fatalf("GetThreads = %s, Failed", err)
+	}
+	if len(threads) < 1 || threads[0].Name == "" {
+		t.Fatalf("GetThreads = %#v, Failed", threads)
+	}
+
+	end := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(end) {
+	}
+
+	threads, err = s.GetThreads(os.Getpid())
+	if err != nil {
+		t.Fatalf("GetThreads = %s, Failed", err)
+	}
+	// CPUを使ったスレッドが先頭に来る
+	if threads[0].CpuPercent <= 0 {
+		t.Errorf("GetThreads = %#v, Failed", threads[0])
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+// readThreads スレッド毎の情報はLinuxのみ対応
+func readThreads(pid int) ([]threadStat, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,51 @@
+package goproc
+
+import (
+	"fmt"
+)
+
+// Topの並び順
+type SortBy string
+
+const (
+	SortByCPU     SortBy = "cpu"
+	SortByRSS     SortBy = "rss"
+	SortByIO      SortBy = "io"
+	SortByThreads SortBy = "threads"
+	SortByFDs     SortBy = "fds"
+)
+
+// Top 条件に合うプロセスを使用量の多い順にn個、マシン全体の情報と一緒に返す。nが0以下なら全部
+// CPU使用率と1秒あたりのI/Oは全プロセスまとめて1秒計測する
+func Top(n int, by SortBy, f Filter) (*Listing, error) {
+	return top(nil, n, by, f)
+}
+
+// Top Topと同じ。CPU使用率と1秒あたりのI/Oは前回呼び出した時からの値になるのでブロッキングしない
+// 定期的に呼び出すステータス画面等ではこちらを使う
+func (s *Sampler) Top(n int, by SortBy, f Filter) (*Listing, error) {
+	return top(s, n, by, f)
+}
+
+func top(s *Sampler, n int, by SortBy, f Filter) (*Listing, error) {
+	switch by {
+	case SortByCPU, SortByRSS, SortByIO, SortByThreads, SortByFDs:
+	default:
+		return nil, fmt.Errorf("unknown sort by: %q", by)
+	}
+	pids, err := FindPids(f)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		s = primeSampler(pids)
+	}
+	ret := s.listProcesses(pids)
+	if err := SortProcesses(ret, "-"+string(by)); err != nil {
+		return nil, err
+	}
+	if n > 0 && len(ret) > n {
+		ret = ret[:n]
+	}
+	return s.newListing(ret)
+}
@@ -0,0 +1,46 @@
+package goproc_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gozuk16/goproc"
+)
+
+func TestTop(t *testing.T) {
+	l, err := goproc.Top(3, goproc.SortByThreads, goproc.Filter{})
+	if err != nil {
+		t.Fatalf("Top = %s, Failed", err)
+	}
+	if l.Host == nil {
+		t.Errorf("Top host = nil, Failed")
+	}
+	ps := l.Processes
+	if len(ps) == 0 || len(ps) > 3 {
+		t.Fatalf("Top = %d, Failed", len(ps))
+	}
+	for i := 1; i < len(ps); i++ {
+		if ps[i-1].NumThreads < ps[i].NumThreads {
+			t.Errorf("Top[%d] = %d < %d, Failed", i, ps[i-1].NumThreads, ps[i].NumThreads)
+		}
+	}
+
+	s := goproc.NewSampler()
+	l, err = s.Top(0, goproc.SortByCPU, goproc.Filter{ParentPid: os.Getppid()})
+	if err != nil {
+		t.Fatalf("Sampler.Top = %s, Failed", err)
+	}
+	found := false
+	for _, p := range l.Processes {
+		if p.Pid == os.Getpid() {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Sampler.Top = %d processes, self not found, Failed", len(l.Processes))
+	}
+
+	if _, err := goproc.Top(1, goproc.SortBy("name"), goproc.Filter{}); err == nil {
+		t.Error("Top(unknown sort) = nil, Failed")
+	}
+}
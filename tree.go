@@ -0,0 +1,46 @@
+package goproc
+
+import (
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// processTree 全プロセスを走査して親PIDから子PIDの一覧を引けるmapを返す
+func processTree() (map[int][]int, error) {
+	pids, err := process.Pids()
+	if err != nil {
+		return nil, err
+	}
+	tree := map[int][]int{}
+	for _, pid := range pids {
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			continue
+		}
+		ppid, err := p.Ppid()
+		if err != nil {
+			continue
+		}
+		tree[int(ppid)] = append(tree[int(ppid)], int(pid))
+	}
+	return tree, nil
+}
+
+// descendants 指定されたPIDの全子孫のPIDを返す(子だけでなく孫以降も含む)
+func descendants(pid int, tree map[int][]int) []int {
+	ret := []int{}
+	visited := map[int]bool{pid: true}
+	queue := []int{pid}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, c := range tree[cur] {
+			if visited[c] {
+				continue
+			}
+			visited[c] = true
+			ret = append(ret, c)
+			queue = append(queue, c)
+		}
+	}
+	return ret
+}
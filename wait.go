@@ -0,0 +1,54 @@
+package goproc
+
+import (
+	"context"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// WaitForExit 指定されたプロセスが終了するまで待つ。子プロセスでなくてもよい
+// 呼び出した時の起動時刻と比べるので、待っている間にPIDが再利用されても終了とみなす
+// 既に居なければすぐにnilを返し、ctxが終わればctx.Err()を返す
+func WaitForExit(ctx context.Context, pid int) error {
+	createtime, ok := processCreateTime(pid)
+	if !ok {
+		return nil
+	}
+	return waitForExit(ctx, pid, createtime)
+}
+
+// pollExit WatchIntervalの間隔でプロセスが終了したか確認する
+func pollExit(ctx context.Context, pid int, createtime int64) error {
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+	for {
+		if cur, ok := processCreateTime(pid); !ok || cur != createtime {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// processCreateTime プロセスの起動時刻(ミリ秒)を返す。居ないかゾンビならfalse
+func processCreateTime(pid int) (int64, bool) {
+	if pid <= 0 {
+		return 0, false
+	}
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return 0, false
+	}
+	createtime, err := p.CreateTime()
+	if err != nil {
+		return 0, false
+	}
+	if status, err := p.Status(); err == nil && len(status) > 0 && status[0] == process.Zombie {
+		return 0, false
+	}
+	return createtime, true
+}
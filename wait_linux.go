@@ -0,0 +1,59 @@
+package goproc
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// waitForExit pidfdをpollして待つ。pidfdが使えなければ(Linux 5.3未満)定期的に確認する
+func waitForExit(ctx context.Context, pid int, createtime int64) error {
+	fd, err := pidfdOpen(pid)
+	if errors.Is(err, unix.ESRCH) {
+		return nil
+	}
+	if err != nil {
+		return pollExit(ctx, pid, createtime)
+	}
+	defer unix.Close(fd)
+
+	// 起動時刻を取ってからpidfdを開くまでの間にPIDが再利用されていないか確認する
+	if cur, ok := processCreateTime(pid); !ok || cur != createtime {
+		return nil
+	}
+
+	// ctxが終わったらすぐにpollから戻れるように、パイプも一緒に待つ
+	var p [2]int
+	if err := unix.Pipe2(p[:], unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		return pollExit(ctx, pid, createtime)
+	}
+	defer unix.Close(p[0])
+	defer unix.Close(p[1])
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			unix.Write(p[1], []byte{0})
+		case <-stop:
+		}
+	}()
+
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}, {Fd: int32(p[0]), Events: unix.POLLIN}}
+	for {
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return pollExit(ctx, pid, createtime)
+		}
+		if fds[0].Revents != 0 {
+			return nil
+		}
+		if fds[1].Revents != 0 {
+			return ctx.Err()
+		}
+	}
+}
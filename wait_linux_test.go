@@ -0,0 +1,55 @@
+package goproc
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestWaitForExit(t *testing.T) {
+	if err := WaitForExit(context.Background(), 999999); err != nil {
+		t.Errorf("WaitForExit(not exist) = %s, Failed", err)
+	}
+
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	pid := cmd.Process.Pid
+
+	// キャンセルされたらすぐに戻る
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := WaitForExit(ctx, pid); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForExit(timeout) = %v, Failed", err)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("WaitForExit(timeout) took %v, Failed", elapsed)
+	}
+
+	done := make(chan error, 2)
+	go func() { done <- WaitForExit(context.Background(), pid) }()
+	createtime, _ := processCreateTime(pid)
+	go func() { done <- pollExit(context.Background(), pid, createtime) }()
+	time.Sleep(100 * time.Millisecond)
+	cmd.Process.Kill()
+	cmd.Wait()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("WaitForExit = %s, Failed", err)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("WaitForExit did not return, Failed")
+		}
+	}
+
+	// 起動時刻が違えばPIDが再利用されたとみなす
+	if err := pollExit(context.Background(), pid, createtime-1000); err != nil {
+		t.Errorf("pollExit(reused) = %s, Failed", err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"context"
+)
+
+// waitForExit Linux以外は定期的に確認する
+func waitForExit(ctx context.Context, pid int, createtime int64) error {
+	return pollExit(ctx, pid, createtime)
+}
@@ -0,0 +1,320 @@
+package goproc
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// WatchInterval /procを見て検出する時の間隔
+var WatchInterval = 500 * time.Millisecond
+
+// プロセスイベントの種類
+type EventType string
+
+const (
+	EventFork EventType = "fork"
+	EventExec EventType = "exec"
+	EventExit EventType = "exit"
+	// 受信が追いつかずにイベントを取りこぼした(Pid等は空)。WatchFilterに関係なく通知する
+	EventOverflow EventType = "overflow"
+)
+
+// プロセスイベント。ExitCodeは終了の時だけで、取れなければ-1(シグナルで終了した時も-1)
+type ProcessEvent struct {
+	Type     EventType `json:"type"`
+	Pid      int       `json:"pid"`
+	Ppid     int       `json:"ppid"`
+	Name     string    `json:"name"`
+	Exe      string    `json:"exe"`
+	ExitCode int       `json:"exitCode"`
+	Signal   string    `json:"signal"`
+	Time     time.Time `json:"time"`
+}
+
+// Watchで通知するイベントの条件
+type WatchFilter struct {
+	// 監視するPID。指定するとこのプロセスの終了だけを通知する(Linuxはpidfd)
+	Pids []int `json:"pids"`
+	// プロセスの条件。fork、execの時点で判定し、合ったプロセスは終了も通知する
+	// 親PID、名前、実行ファイルはイベントを読んだ時の値で判定する。EventOverflowの後は以前に合ったプロセスの終了は通知しない
+	Filter Filter `json:"filter"`
+	// 通知するイベントの種類。空なら全部
+	Types []EventType `json:"types"`
+}
+
+// イベントを条件で絞り込んで送る
+type watcher struct {
+	f       WatchFilter
+	re      *regexp.Regexp
+	pids    map[int]bool
+	matched map[int]bool
+	ch      chan ProcessEvent
+}
+
+// Watch プロセスの起動(fork)、実行ファイルの切り替え(exec)、終了(exit)を通知する
+// Linuxはnetlinkのプロセスコネクタ、PIDの指定があればpidfdを使い、使えなければ(権限が無い等)/procをWatchIntervalの間隔で見る
+// /procを見る場合は間隔内で終わったプロセスは検出できず、終了コードも取れない。ctxが終わるとチャネルを閉じる
+// プロセスコネクタの受信が追いつかずイベントを取りこぼした時はEventOverflowを送る
+func Watch(ctx context.Context, f WatchFilter) (<-chan ProcessEvent, error) {
+	w := &watcher{f: f, pids: map[int]bool{}, matched: map[int]bool{}, ch: make(chan ProcessEvent, 64)}
+	if f.Filter.NameRegex != "" {
+		var err error
+		w.re, err = regexp.Compile(f.Filter.NameRegex)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, pid := range f.Pids {
+		w.pids[pid] = true
+	}
+	// 既に動いているプロセスの終了も通知できるように、条件に合うものを覚えておく
+	if w.hasFilter() {
+		pids, err := FindPids(f.Filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, pid := range pids {
+			w.matched[pid] = true
+		}
+	}
+
+	// 監視を始めてから戻らないと、戻った直後のイベントを取りこぼす
+	run := w.open()
+	go func() {
+		defer close(w.ch)
+		run(ctx)
+	}()
+	return w.ch, nil
+}
+
+func (w *watcher) hasFilter() bool {
+	return w.f.Filter != (Filter{})
+}
+
+// send 条件に合うイベントを送る。ctxが終わっていればfalse
+func (w *watcher) send(ctx context.Context, e ProcessEvent) bool {
+	if !w.accept(e) {
+		return ctx.Err() == nil
+	}
+	select {
+	case w.ch <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// accept イベントが条件に合うか判定する
+func (w *watcher) accept(e ProcessEvent) bool {
+	if e.Type == EventOverflow {
+		// 取りこぼした終了イベントの分が残り続けないように、条件に合ったプロセスを忘れる
+		w.matched = map[int]bool{}
+		return true
+	}
+	if len(w.pids) > 0 && !w.pids[e.Pid] {
+		return false
+	}
+	if w.hasFilter() {
+		if e.Type == EventExit {
+			if !w.matched[e.Pid] {
+				return false
+			}
+			delete(w.matched, e.Pid)
+		} else {
+			if w.matchEvent(e) {
+				w.matched[e.Pid] = true
+			} else {
+				delete(w.matched, e.Pid)
+				return false
+			}
+		}
+	}
+	if len(w.f.Types) == 0 {
+		return true
+	}
+	for _, t := range w.f.Types {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// matchEvent イベントを読んだ時の親PID、名前、実行ファイルで条件を判定する
+// すぐに終了したプロセスも判定できるように、/procのプロセスはそれ以外の条件か値が取れていない時だけ見る
+func (w *watcher) matchEvent(e ProcessEvent) bool {
+	rest, re := w.f.Filter, w.re
+	if e.Ppid != 0 && rest.ParentPid != 0 {
+		if e.Ppid != rest.ParentPid {
+			return false
+		}
+		rest.ParentPid = 0
+	}
+	if e.Name != "" && re != nil {
+		if !re.MatchString(e.Name) {
+			return false
+		}
+		rest.NameRegex, re = "", nil
+	}
+	if e.Exe != "" && rest.ExePath != "" {
+		if filepath.Clean(e.Exe) != filepath.Clean(rest.ExePath) {
+			return false
+		}
+		rest.ExePath = ""
+	}
+	if rest == (Filter{}) {
+		return true
+	}
+	p, err := process.NewProcess(int32(e.Pid))
+	return err == nil && rest.match(p, re)
+}
+
+// 間隔を空けて見る時に比べる値。差分を取るだけなのでコマンドラインやメモリは読まない
+// 比べるのは親PID、起動時刻、実行ファイルで、名前はイベントに付けるために持つ
+type pollEntry struct {
+	ppid  int
+	start int64
+	exe   string
+	name  string
+}
+
+// openPoll 全プロセスのPID、親PID、起動時刻、実行ファイルの差分からイベントを作る
+func (w *watcher) openPoll() func(ctx context.Context) {
+	prev := pollScan()
+	return func(ctx context.Context) {
+		ticker := time.NewTicker(WatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			next := pollScan()
+			if next == nil {
+				continue
+			}
+			for _, e := range pollEvents(prev, next, time.Now()) {
+				if !w.send(ctx, e) {
+					return
+				}
+			}
+			prev = next
+		}
+	}
+}
+
+// pollEvents 前回との差分をイベントにする。起動時刻が変わったPIDは再利用されたので終了と起動にする
+func pollEvents(prev, next map[int]pollEntry, now time.Time) []ProcessEvent {
+	event := func(t EventType, pid int, p pollEntry) ProcessEvent {
+		e := ProcessEvent{Type: t, Pid: pid, Ppid: p.ppid, Name: p.name, Exe: p.exe, Time: now}
+		if t == EventExit {
+			e.ExitCode = -1
+		}
+		return e
+	}
+	ret := []ProcessEvent{}
+	for _, pid := range sortedPollPids(next) {
+		n := next[pid]
+		p, ok := prev[pid]
+		switch {
+		case ok && p.start != n.start:
+			ret = append(ret, event(EventExit, pid, p), event(EventFork, pid, n))
+		case !ok:
+			ret = append(ret, event(EventFork, pid, n))
+		case p.exe != n.exe:
+			ret = append(ret, event(EventExec, pid, n))
+		}
+	}
+	for _, pid := range sortedPollPids(prev) {
+		if _, ok := next[pid]; !ok {
+			ret = append(ret, event(EventExit, pid, prev[pid]))
+		}
+	}
+	return ret
+}
+
+func sortedPollPids(m map[int]pollEntry) []int {
+	pids := make([]int, 0, len(m))
+	for pid := range m {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// openPollPids 指定されたPIDだけを見て終了を検出する。起動時刻が変わったらPIDが再利用されたとみなす
+func (w *watcher) openPollPids() func(ctx context.Context) {
+	watching := map[int]SnapshotProcess{}
+	exited := []ProcessEvent{}
+	for pid := range w.pids {
+		if sp, ok := snapshotProcess(pid); ok {
+			watching[pid] = sp
+		} else {
+			exited = append(exited, ProcessEvent{Type: EventExit, Pid: pid, ExitCode: -1, Time: time.Now()})
+		}
+	}
+	return func(ctx context.Context) {
+		for _, e := range exited {
+			if !w.send(ctx, e) {
+				return
+			}
+		}
+		ticker := time.NewTicker(WatchInterval)
+		defer ticker.Stop()
+		for len(watching) > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			pids := []int{}
+			for pid := range watching {
+				pids = append(pids, pid)
+			}
+			sort.Ints(pids)
+			for _, pid := range pids {
+				prev := watching[pid]
+				if cur, ok := snapshotProcess(pid); ok && cur.StartTime.Equal(prev.StartTime) {
+					continue
+				}
+				delete(watching, pid)
+				e := ProcessEvent{Type: EventExit, Pid: pid, Ppid: prev.Ppid, Name: prev.Name, Exe: prev.Exe, ExitCode: -1, Time: time.Now()}
+				if !w.send(ctx, e) {
+					return
+				}
+			}
+		}
+		<-ctx.Done()
+	}
+}
+
+// snapshotProcess 1プロセス分のスナップショットを取る。終了していればfalse
+func snapshotProcess(pid int) (SnapshotProcess, bool) {
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return SnapshotProcess{}, false
+	}
+	createtime, err := p.CreateTime()
+	if err != nil {
+		return SnapshotProcess{}, false
+	}
+	// ゾンビは終了したとみなす
+	if status, err := p.Status(); err == nil && len(status) > 0 && status[0] == process.Zombie {
+		return SnapshotProcess{}, false
+	}
+	sp := SnapshotProcess{
+		Pid:       pid,
+		StartTime: time.Unix(createtime/1000, (createtime%1000)*int64(time.Millisecond)),
+	}
+	ppid, _ := p.Ppid()
+	sp.Ppid = int(ppid)
+	sp.Name, _ = p.Name()
+	sp.Exe, _ = p.Exe()
+	return sp, true
+}
@@ -0,0 +1,286 @@
+package goproc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// netlinkのプロセスコネクタ(linux/cn_proc.h、linux/connector.h)
+const (
+	cnIdxProc         = 0x1
+	cnValProc         = 0x1
+	procCnMcastListen = 1
+	procEventFork     = 0x00000001
+	procEventExec     = 0x00000002
+	procEventExit     = 0x80000000
+	// struct cn_msgの大きさ
+	cnMsgLen = 20
+)
+
+// open PIDの指定があればpidfd、無ければプロセスコネクタを使い、使えなければ/procを見る
+func (w *watcher) open() func(ctx context.Context) {
+	if len(w.pids) > 0 {
+		if run, err := w.openPidfds(); err == nil {
+			return run
+		}
+		return w.openPollPids()
+	}
+	// CAP_NET_ADMINが無いとbindでEPERMになる
+	if fd, err := openProcConnector(); err == nil {
+		return func(ctx context.Context) {
+			defer unix.Close(fd)
+			w.readProcConnector(ctx, fd)
+		}
+	}
+	return w.openPoll()
+}
+
+// openPidfds 指定されたPIDのpidfdを開いて終了を待つ。既に居ないPIDはすぐに終了を通知する
+func (w *watcher) openPidfds() (func(ctx context.Context), error) {
+	pids := []int{}
+	for pid := range w.pids {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+
+	fds := []unix.PollFd{}
+	watching := map[int32]SnapshotProcess{}
+	exited := []ProcessEvent{}
+	for _, pid := range pids {
+		fd, err := pidfdOpen(pid)
+		if errors.Is(err, unix.ESRCH) {
+			exited = append(exited, ProcessEvent{Type: EventExit, Pid: pid, ExitCode: -1, Time: time.Now()})
+			continue
+		}
+		if err != nil {
+			// カーネルが古い(ENOSYS)等
+			for _, f := range fds {
+				unix.Close(int(f.Fd))
+			}
+			return nil, err
+		}
+		sp, _ := snapshotProcess(pid)
+		sp.Pid = pid
+		fds = append(fds, unix.PollFd{Fd: int32(fd), Events: unix.POLLIN})
+		watching[int32(fd)] = sp
+	}
+
+	return func(ctx context.Context) {
+		defer func() {
+			for _, f := range fds {
+				unix.Close(int(f.Fd))
+			}
+		}()
+		for _, e := range exited {
+			if !w.send(ctx, e) {
+				return
+			}
+		}
+		for len(fds) > 0 {
+			if ctx.Err() != nil {
+				return
+			}
+			// ctxの終了を確認できるようにタイムアウトを付ける
+			n, err := unix.Poll(fds, int(WatchInterval/time.Millisecond))
+			if err != nil && err != unix.EINTR {
+				return
+			}
+			if n <= 0 {
+				continue
+			}
+			rest := []unix.PollFd{}
+			for _, f := range fds {
+				if f.Revents == 0 {
+					rest = append(rest, f)
+					continue
+				}
+				unix.Close(int(f.Fd))
+				sp := watching[f.Fd]
+				// 子プロセスでなければ終了コードは取れない(子プロセスでも回収は呼び出し元に任せる)
+				e := ProcessEvent{Type: EventExit, Pid: sp.Pid, Ppid: sp.Ppid, Name: sp.Name, Exe: sp.Exe, ExitCode: -1, Time: time.Now()}
+				if !w.send(ctx, e) {
+					fds = rest
+					return
+				}
+			}
+			fds = rest
+		}
+		<-ctx.Done()
+	}, nil
+}
+
+// openProcConnector プロセスコネクタのnetlinkソケットを開いてイベントの受信を始める
+func openProcConnector() (int, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_CONNECTOR)
+	if err != nil {
+		return -1, err
+	}
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: cnIdxProc}); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+
+	// nlmsghdr + cn_msg + PROC_CN_MCAST_LISTEN
+	b := make([]byte, unix.NLMSG_HDRLEN+cnMsgLen+4)
+	nativeEndian.PutUint32(b[0:], uint32(len(b)))
+	nativeEndian.PutUint16(b[4:], unix.NLMSG_DONE)
+	nativeEndian.PutUint32(b[unix.NLMSG_HDRLEN:], cnIdxProc)
+	nativeEndian.PutUint32(b[unix.NLMSG_HDRLEN+4:], cnValProc)
+	nativeEndian.PutUint16(b[unix.NLMSG_HDRLEN+16:], 4)
+	nativeEndian.PutUint32(b[unix.NLMSG_HDRLEN+cnMsgLen:], procCnMcastListen)
+	if err := unix.Sendto(fd, b, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+
+	// ctxの終了を確認できるように受信にタイムアウトを付ける
+	tv := unix.NsecToTimeval(int64(WatchInterval))
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// readProcConnector プロセスコネクタのイベントを受信して送る
+func (w *watcher) readProcConnector(ctx context.Context, fd int) {
+	buf := make([]byte, os.Getpagesize())
+	// 終了したプロセスが先に回収されると名前が読めないので、fork、execの時に読んだ値を覚えておく
+	known := map[int]ProcessEvent{}
+	for ctx.Err() == nil {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			// タイムアウト、割り込みは続ける
+			if err == unix.EAGAIN || err == unix.EINTR {
+				continue
+			}
+			// 受信バッファがあふれてイベントを取りこぼしたことを知らせて続ける
+			if err == unix.ENOBUFS {
+				if !w.send(ctx, ProcessEvent{Type: EventOverflow, Time: time.Now()}) {
+					return
+				}
+				continue
+			}
+			return
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, m := range msgs {
+			if len(m.Data) < cnMsgLen {
+				continue
+			}
+			e, ok := parseProcEvent(m.Data[cnMsgLen:])
+			if !ok {
+				continue
+			}
+			// 終了したプロセスもゾンビのうちはcommが読める
+			e.Name = readComm(e.Pid)
+			if e.Type == EventExit {
+				prev := known[e.Pid]
+				delete(known, e.Pid)
+				if e.Name == "" {
+					e.Name = prev.Name
+				}
+				e.Exe = prev.Exe
+				if e.Ppid == 0 {
+					e.Ppid = prev.Ppid
+				}
+			} else {
+				e.Exe, _ = os.Readlink(fmt.Sprintf("/proc/%d/exe", e.Pid))
+				if e.Ppid == 0 {
+					e.Ppid = readPpid(e.Pid)
+				}
+				known[e.Pid] = e
+			}
+			if !w.send(ctx, e) {
+				return
+			}
+		}
+	}
+}
+
+// parseProcEvent struct proc_eventからイベントを作る。スレッドのイベントは無視する
+func parseProcEvent(b []byte) (ProcessEvent, bool) {
+	// what, cpu, timestamp_nsの後にイベント毎のデータが続く
+	const head = 16
+	if len(b) < head+8 {
+		return ProcessEvent{}, false
+	}
+	what := nativeEndian.Uint32(b[0:])
+	u32 := func(i int) int { return int(nativeEndian.Uint32(b[head+i*4:])) }
+	e := ProcessEvent{Time: time.Now()}
+
+	switch what {
+	case procEventFork:
+		// parent_pid, parent_tgid, child_pid, child_tgid
+		if len(b) < head+16 || u32(2) != u32(3) {
+			return e, false
+		}
+		e.Type, e.Pid, e.Ppid = EventFork, u32(3), u32(1)
+	case procEventExec:
+		// process_pid, process_tgid
+		if u32(0) != u32(1) {
+			return e, false
+		}
+		e.Type, e.Pid = EventExec, u32(1)
+	case procEventExit:
+		// process_pid, process_tgid, exit_code, exit_signal, (parent_pid, parent_tgid)
+		if len(b) < head+16 || u32(0) != u32(1) {
+			return e, false
+		}
+		e.Type, e.Pid = EventExit, u32(1)
+		status := syscall.WaitStatus(u32(2))
+		e.ExitCode = status.ExitStatus()
+		if status.Signaled() {
+			e.Signal = unix.SignalName(status.Signal())
+		}
+		if len(b) >= head+24 {
+			e.Ppid = u32(5)
+		}
+	default:
+		return e, false
+	}
+	return e, true
+}
+
+// readPpid /proc/<pid>/statから親のPIDを返す。読めなければ0
+func readPpid(pid int) int {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0
+	}
+	fields := procStatFields(string(b))
+	if len(fields) < 2 {
+		return 0
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	return ppid
+}
+
+// pollScan 全プロセスの/proc/<pid>/statと実行ファイルのリンクだけを読む。ゾンビは終了したとみなす
+func pollScan() map[int]pollEntry {
+	procs := scanProcStat()
+	if procs == nil {
+		return nil
+	}
+	ret := make(map[int]pollEntry, len(procs))
+	for pid, p := range procs {
+		if p.state == "Z" {
+			continue
+		}
+		start, _ := strconv.ParseInt(p.start, 10, 64)
+		exe, _ := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+		ret[pid] = pollEntry{ppid: p.ppid, start: start, exe: exe, name: p.name}
+	}
+	return ret
+}
@@ -0,0 +1,191 @@
+package goproc
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestParseProcEvent(t *testing.T) {
+	event := func(what uint32, data ...uint32) []byte {
+		b := make([]byte, 16+len(data)*4)
+		nativeEndian.PutUint32(b[0:], what)
+		for i, d := range data {
+			nativeEndian.PutUint32(b[16+i*4:], d)
+		}
+		return b
+	}
+
+	cases := []struct {
+		in     []byte
+		ok     bool
+		except ProcessEvent
+		msg    string
+	}{
+		{event(procEventFork, 10, 10, 20, 20), true, ProcessEvent{Type: EventFork, Pid: 20, Ppid: 10}, "fork"},
+		{event(procEventFork, 10, 10, 21, 20), false, ProcessEvent{}, "スレッドの作成は無視"},
+		{event(procEventExec, 20, 20), true, ProcessEvent{Type: EventExec, Pid: 20}, "exec"},
+		{event(procEventExit, 20, 20, 3<<8, 17, 10, 10), true, ProcessEvent{Type: EventExit, Pid: 20, Ppid: 10, ExitCode: 3}, "exit"},
+		{event(procEventExit, 20, 20, 9, 17), true, ProcessEvent{Type: EventExit, Pid: 20, ExitCode: -1, Signal: "SIGKILL"}, "シグナルで終了"},
+		{event(0x40, 20, 20, 0, 0), false, ProcessEvent{}, "対象外のイベント"},
+	}
+	for _, c := range cases {
+		t.Run(c.msg, func(t *testing.T) {
+			e, ok := parseProcEvent(c.in)
+			if ok != c.ok {
+				t.Fatalf("parseProcEvent = %v, expect = %v, Failed", ok, c.ok)
+			}
+			if !ok {
+				return
+			}
+			if e.Type != c.except.Type || e.Pid != c.except.Pid || e.Ppid != c.except.Ppid || e.ExitCode != c.except.ExitCode || e.Signal != c.except.Signal {
+				t.Errorf("parseProcEvent = %#v, Failed", e)
+			}
+		})
+	}
+}
+
+// waitEvent 条件に合うイベントを待つ
+func waitEvent(t *testing.T, ch <-chan ProcessEvent, typ EventType, pid int) ProcessEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				t.Fatalf("channel closed before %s of %d, Failed", typ, pid)
+			}
+			if e.Type == typ && e.Pid == pid {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting %s of %d, Failed", typ, pid)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := Watch(ctx, WatchFilter{Filter: Filter{ParentPid: os.Getpid(), NameRegex: "^sleep$"}})
+	if err != nil {
+		t.Fatalf("Watch = %s, Failed", err)
+	}
+	cmd := exec.Command("sleep", "0.7")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	pid := cmd.Process.Pid
+	cmd.Wait()
+	// プロセスコネクタならexecとexit、/procを見る場合はforkとexit
+	e := waitEvent(t, ch, EventExit, pid)
+	if e.Name != "sleep" {
+		t.Errorf("exit event = %#v, Failed", e)
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestWatchPids(t *testing.T) {
+	for _, source := range []string{"pidfd", "poll"} {
+		t.Run(source, func(t *testing.T) {
+			cmd := exec.Command("sleep", "10")
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			pid := cmd.Process.Pid
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			w := &watcher{pids: map[int]bool{pid: true, 999999: true}, matched: map[int]bool{}, ch: make(chan ProcessEvent, 8)}
+			var run func(ctx context.Context)
+			if source == "pidfd" {
+				var err error
+				if run, err = w.openPidfds(); err != nil {
+					cmd.Process.Kill()
+					cmd.Wait()
+					t.Skipf("pidfd_open = %s", err)
+				}
+			} else {
+				run = w.openPollPids()
+			}
+			go func() {
+				defer close(w.ch)
+				run(ctx)
+			}()
+			// 存在しないPIDはすぐに終了を通知する
+			waitEvent(t, w.ch, EventExit, 999999)
+			cmd.Process.Kill()
+			cmd.Wait()
+			e := waitEvent(t, w.ch, EventExit, pid)
+			if e.Name != "sleep" || e.ExitCode != -1 {
+				t.Errorf("exit event = %#v, Failed", e)
+			}
+		})
+	}
+}
+
+func TestPollEvents(t *testing.T) {
+	prev := map[int]pollEntry{
+		10: {ppid: 1, start: 100, exe: "/bin/sh", name: "sh"},
+		20: {ppid: 1, start: 200, exe: "/bin/sleep", name: "sleep"},
+		30: {ppid: 10, start: 300, exe: "/bin/sh", name: "sh"},
+	}
+	next := map[int]pollEntry{
+		10: {ppid: 1, start: 100, exe: "/bin/sh", name: "sh"},
+		20: {ppid: 1, start: 250, exe: "/bin/cat", name: "cat"},
+		30: {ppid: 10, start: 300, exe: "/bin/ls", name: "ls"},
+		40: {ppid: 30, start: 400, exe: "/bin/true", name: "true"},
+	}
+	delete(next, 10)
+	expect := []struct {
+		typ EventType
+		pid int
+	}{{EventExit, 20}, {EventFork, 20}, {EventExec, 30}, {EventFork, 40}, {EventExit, 10}}
+	got := pollEvents(prev, next, time.Now())
+	if len(got) != len(expect) {
+		t.Fatalf("pollEvents = %#v, Failed", got)
+	}
+	for i, e := range expect {
+		if got[i].Type != e.typ || got[i].Pid != e.pid {
+			t.Errorf("pollEvents[%d] = %s %d, expect = %s %d, Failed", i, got[i].Type, got[i].Pid, e.typ, e.pid)
+		}
+	}
+	if got[0].Name != "sleep" || got[0].ExitCode != -1 || got[1].Name != "cat" {
+		t.Errorf("pollEvents reuse = %#v, Failed", got[:2])
+	}
+
+	w := &watcher{f: WatchFilter{Types: []EventType{EventExit}}, pids: map[int]bool{1: true}, matched: map[int]bool{}}
+	if !w.accept(ProcessEvent{Type: EventOverflow}) {
+		t.Error("accept(overflow) = false, Failed")
+	}
+}
+
+func TestWatchAccept(t *testing.T) {
+	f := WatchFilter{Filter: Filter{ParentPid: 10, NameRegex: "^sleep$", ExePath: "/bin/sleep"}}
+	w := &watcher{f: f, re: regexp.MustCompile(f.Filter.NameRegex), pids: map[int]bool{}, matched: map[int]bool{}}
+
+	// 終了して/procに無いプロセスも読んだ時の値で判定する
+	if !w.accept(ProcessEvent{Type: EventExec, Pid: 999999, Ppid: 10, Name: "sleep", Exe: "/bin/sleep"}) {
+		t.Error("accept(exec) = false, Failed")
+	}
+	if w.accept(ProcessEvent{Type: EventExec, Pid: 999998, Ppid: 10, Name: "cat", Exe: "/bin/cat"}) {
+		t.Error("accept(other) = true, Failed")
+	}
+	if !w.accept(ProcessEvent{Type: EventExit, Pid: 999999}) || w.accept(ProcessEvent{Type: EventExit, Pid: 999998}) {
+		t.Error("accept(exit) Failed")
+	}
+
+	// 取りこぼした後は条件に合ったプロセスを忘れる
+	w.accept(ProcessEvent{Type: EventExec, Pid: 999997, Ppid: 10, Name: "sleep", Exe: "/bin/sleep"})
+	w.accept(ProcessEvent{Type: EventOverflow})
+	if len(w.matched) != 0 {
+		t.Errorf("matched = %v, Failed", w.matched)
+	}
+}
@@ -0,0 +1,45 @@
+//go:build !linux
+// +build !linux
+
+package goproc
+
+import (
+	"context"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// open Linux以外は/procに当たるものを定期的に見るだけ
+func (w *watcher) open() func(ctx context.Context) {
+	if len(w.pids) > 0 {
+		return w.openPollPids()
+	}
+	return w.openPoll()
+}
+
+// pollScan 全プロセスの親PID、起動時刻、実行ファイル、名前だけを取る。ゾンビは終了したとみなす
+func pollScan() map[int]pollEntry {
+	pids, err := process.Pids()
+	if err != nil {
+		return nil
+	}
+	ret := make(map[int]pollEntry, len(pids))
+	for _, pid := range pids {
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			continue
+		}
+		start, err := p.CreateTime()
+		if err != nil {
+			continue
+		}
+		if status, err := p.Status(); err == nil && len(status) > 0 && status[0] == process.Zombie {
+			continue
+		}
+		ppid, _ := p.Ppid()
+		exe, _ := p.Exe()
+		name, _ := p.Name()
+		ret[int(pid)] = pollEntry{ppid: int(ppid), start: start, exe: exe, name: name}
+	}
+	return ret
+}